@@ -0,0 +1,338 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gopkg.in/yaml.v3"
+)
+
+// Announcement is a course-wide or section notice stored as a Markdown file
+// in the announcements directory
+type Announcement struct {
+	ID        string     `json:"id"`
+	Title     string     `json:"title"`
+	Section   string     `json:"section,omitempty"` // Empty for course-wide notices
+	Pinned    bool       `json:"pinned"`
+	Starts    string     `json:"starts,omitempty"`
+	Expires   string     `json:"expires,omitempty"`
+	StartsAt  *time.Time `json:"starts_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Content   string     `json:"content"`
+	CreatedAt time.Time  `json:"created_at"`
+	FilePath  string     `json:"-"`
+}
+
+type AnnouncementMetadata struct {
+	Title   string `yaml:"title"`
+	Section string `yaml:"section,omitempty"`
+	Pinned  bool   `yaml:"pinned,omitempty"`
+	Starts  string `yaml:"starts,omitempty"`
+	Expires string `yaml:"expires,omitempty"`
+}
+
+func (s *Server) announcementsDir() string {
+	return filepath.Join(s.lessonsDir, "announcements")
+}
+
+// active reports whether the announcement should be shown at the given time
+func (a *Announcement) active(now time.Time) bool {
+	if a.StartsAt != nil && now.Before(*a.StartsAt) {
+		return false
+	}
+	if a.ExpiresAt != nil && !now.Before(*a.ExpiresAt) {
+		return false
+	}
+	return true
+}
+
+// parseAnnouncement reads one announcement file. Date-only expiry runs to the
+// end of that day.
+func parseAnnouncement(filePath string) (*Announcement, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	announcement := &Announcement{
+		ID:        strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath)),
+		CreatedAt: fileInfo.ModTime(),
+		FilePath:  filePath,
+		Content:   strings.TrimSpace(string(content)),
+	}
+
+	var metadata AnnouncementMetadata
+	found, err := decodeFrontmatter(string(content), &metadata)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		announcement.Title = metadata.Title
+		announcement.Section = metadata.Section
+		announcement.Pinned = metadata.Pinned
+		announcement.Starts = metadata.Starts
+		announcement.Expires = metadata.Expires
+		_, announcement.Content, _ = cutFrontmatter(string(content))
+	}
+
+	if announcement.Title == "" {
+		announcement.Title = announcement.ID
+	}
+
+	if announcement.Starts != "" {
+		starts, ok := parseCourseDate(announcement.Starts)
+		if !ok {
+			return nil, fmt.Errorf("invalid starts %q", announcement.Starts)
+		}
+		announcement.StartsAt = &starts
+	}
+	if announcement.Expires != "" {
+		expires, ok := parseCourseDate(announcement.Expires)
+		if !ok {
+			return nil, fmt.Errorf("invalid expires %q", announcement.Expires)
+		}
+		if !strings.Contains(announcement.Expires, "T") {
+			expires = expires.AddDate(0, 0, 1)
+		}
+		announcement.ExpiresAt = &expires
+	}
+
+	return announcement, nil
+}
+
+// scanAnnouncements reloads every announcement file. A missing directory
+// just means there are no announcements.
+func (s *Server) scanAnnouncements() error {
+	dir := s.announcementsDir()
+	files, err := filepath.Glob(filepath.Join(dir, "*.md"))
+	if err != nil {
+		return fmt.Errorf("failed to list announcements: %w", err)
+	}
+
+	if _, err := os.Stat(dir); err == nil && s.watcher != nil {
+		if err := s.watcher.Add(dir); err != nil {
+			slog.Warn("failed to watch announcements directory", "dir", dir, "error", err)
+		}
+	}
+
+	var announcements []*Announcement
+	for _, filePath := range files {
+		announcement, err := parseAnnouncement(filePath)
+		if err != nil {
+			slog.Error("announcement parse failed", "file", filePath, "error", err)
+			continue
+		}
+		announcements = append(announcements, announcement)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, announcement := range announcements {
+		if _, exists := s.sections[announcement.Section]; announcement.Section != "" && !exists {
+			slog.Warn("announcement targets unknown section", "id", announcement.ID, "section", announcement.Section)
+		}
+	}
+	s.announcements = announcements
+
+	slog.Info("announcements loaded", "count", len(announcements))
+	return nil
+}
+
+// visibleAnnouncements returns course-wide announcements plus those for the
+// given section, pinned first and then newest first
+func (s *Server) visibleAnnouncements(sectionID string, includeInactive bool) []*Announcement {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	now := time.Now()
+	result := []*Announcement{}
+	for _, announcement := range s.announcements {
+		if sectionID != "" && announcement.Section != "" && announcement.Section != sectionID {
+			continue
+		}
+		if !includeInactive && !announcement.active(now) {
+			continue
+		}
+		result = append(result, announcement)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Pinned != result[j].Pinned {
+			return result[i].Pinned
+		}
+		return announcementTime(result[i]).After(announcementTime(result[j]))
+	})
+	return result
+}
+
+// announcementTime orders announcements by when they went live
+func announcementTime(a *Announcement) time.Time {
+	if a.StartsAt != nil {
+		return *a.StartsAt
+	}
+	return a.CreatedAt
+}
+
+// includeInactive lets instructors review scheduled and expired notices
+// with ?all=true
+func includeInactive(r *http.Request) bool {
+	user := requestUser(r)
+	return r.URL.Query().Get("all") == "true" && user != nil && user.Role == roleInstructor
+}
+
+func (s *Server) handleAnnouncements(w http.ResponseWriter, r *http.Request) {
+	announcements := s.visibleAnnouncements(r.URL.Query().Get("section"), includeInactive(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(announcements)
+}
+
+func (s *Server) handleSectionAnnouncements(w http.ResponseWriter, r *http.Request) {
+	sectionID := mux.Vars(r)["section"]
+
+	s.mutex.RLock()
+	_, exists := s.sections[sectionID]
+	s.mutex.RUnlock()
+
+	if !exists {
+		http.Error(w, "Section not found", http.StatusNotFound)
+		return
+	}
+
+	announcements := s.visibleAnnouncements(sectionID, includeInactive(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(announcements)
+}
+
+type announcementRequest struct {
+	Title   string `json:"title"`
+	Section string `json:"section"`
+	Pinned  bool   `json:"pinned"`
+	Starts  string `json:"starts"`
+	Expires string `json:"expires"`
+	Content string `json:"content"`
+}
+
+// handleCreateAnnouncement writes a new announcement file named after the
+// current date and title
+func (s *Server) handleCreateAnnouncement(w http.ResponseWriter, r *http.Request) {
+	var req announcementRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxLessonSize)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	req.Title = strings.TrimSpace(req.Title)
+	if req.Title == "" {
+		http.Error(w, "Title is required", http.StatusBadRequest)
+		return
+	}
+	for _, date := range []string{req.Starts, req.Expires} {
+		if _, ok := parseCourseDate(date); date != "" && !ok {
+			http.Error(w, fmt.Sprintf("Invalid date %q", date), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Section != "" {
+		s.mutex.RLock()
+		_, exists := s.sections[req.Section]
+		s.mutex.RUnlock()
+		if !exists {
+			http.Error(w, "Section not found", http.StatusBadRequest)
+			return
+		}
+	}
+
+	frontmatter, err := yaml.Marshal(AnnouncementMetadata{
+		Title:   req.Title,
+		Section: req.Section,
+		Pinned:  req.Pinned,
+		Starts:  req.Starts,
+		Expires: req.Expires,
+	})
+	if err != nil {
+		http.Error(w, "Failed to encode announcement", http.StatusInternalServerError)
+		return
+	}
+	data := []byte("---\n" + string(frontmatter) + "---\n\n" + strings.TrimSpace(req.Content) + "\n")
+
+	dir := s.announcementsDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		slog.Error("failed to create announcements directory", "dir", dir, "error", err)
+		http.Error(w, "Failed to save announcement", http.StatusInternalServerError)
+		return
+	}
+
+	id := time.Now().Format("2006-01-02") + "-" + s.generateIDFromTitle(req.Title)
+	s.authoringMutex.Lock()
+	for n := 2; ; n++ {
+		if _, err := os.Stat(filepath.Join(dir, id+".md")); os.IsNotExist(err) {
+			break
+		}
+		id = fmt.Sprintf("%s-%s-%d", time.Now().Format("2006-01-02"), s.generateIDFromTitle(req.Title), n)
+	}
+	err = writeFileAtomic(filepath.Join(dir, id+".md"), data, 0644)
+	s.authoringMutex.Unlock()
+	if err != nil {
+		slog.Error("failed to write announcement", "id", id, "error", err)
+		http.Error(w, "Failed to save announcement", http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.scanAnnouncements(); err != nil {
+		slog.Error("failed to rescan announcements", "error", err)
+	}
+
+	announcement, err := parseAnnouncement(filepath.Join(dir, id+".md"))
+	if err != nil {
+		http.Error(w, "Failed to read saved announcement", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(announcement)
+}
+
+func (s *Server) handleDeleteAnnouncement(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	s.mutex.RLock()
+	var filePath string
+	for _, announcement := range s.announcements {
+		if announcement.ID == id {
+			filePath = announcement.FilePath
+		}
+	}
+	s.mutex.RUnlock()
+
+	if filePath == "" {
+		http.Error(w, "Announcement not found", http.StatusNotFound)
+		return
+	}
+
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		slog.Error("failed to remove announcement", "id", id, "error", err)
+		http.Error(w, "Failed to delete announcement", http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.scanAnnouncements(); err != nil {
+		slog.Error("failed to rescan announcements", "error", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
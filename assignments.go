@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gopkg.in/yaml.v3"
+)
+
+// Assignment is declared in lesson frontmatter under `assignments:` or in a
+// fenced ```assignment block containing the same YAML fields.
+type Assignment struct {
+	ID             string     `json:"id" yaml:"id"`
+	Title          string     `json:"title" yaml:"title"`
+	Description    string     `json:"description,omitempty" yaml:"description"`
+	Points         float64    `json:"points" yaml:"points"`
+	Due            string     `json:"due,omitempty" yaml:"due"`           // YYYY-MM-DD or YYYY-MM-DDTHH:MM
+	DueWeek        int        `json:"due_week,omitempty" yaml:"due_week"` // Global week, defaults to the lesson week
+	DueDay         int        `json:"due_day,omitempty" yaml:"due_day"`   // "Day N" within the due week
+	SubmissionType string     `json:"submission_type" yaml:"submission_type"`
+	Category       string     `json:"category,omitempty" yaml:"category"` // Assessment category ID from the section syllabus
+	Week           int        `json:"week" yaml:"-"`
+	Section        string     `json:"section" yaml:"-"`
+	DueAt          *time.Time `json:"due_at,omitempty" yaml:"-"`
+}
+
+var assignmentBlockPattern = regexp.MustCompile("(?ms)^```assignment[ \\t]*\\r?\\n(.*?)^```")
+
+// parseAssignmentBlocks extracts assignments from fenced ```assignment
+// blocks. A block may hold a single assignment or a YAML list of them.
+func parseAssignmentBlocks(content string) ([]*Assignment, error) {
+	var assignments []*Assignment
+
+	for _, match := range assignmentBlockPattern.FindAllStringSubmatch(content, -1) {
+		var list []*Assignment
+		if err := yaml.Unmarshal([]byte(match[1]), &list); err == nil {
+			assignments = append(assignments, list...)
+			continue
+		}
+
+		var single Assignment
+		if err := yaml.Unmarshal([]byte(match[1]), &single); err != nil {
+			return assignments, fmt.Errorf("invalid assignment block: %w", err)
+		}
+		assignments = append(assignments, &single)
+	}
+
+	return assignments, nil
+}
+
+// normalizeAssignments fills defaults and attaches the lesson location
+func (s *Server) normalizeAssignments(lesson *Lesson, assignments []*Assignment) ([]*Assignment, []string) {
+	var result []*Assignment
+	var warnings []string
+	for _, a := range assignments {
+		if a == nil || strings.TrimSpace(a.Title) == "" {
+			warnings = append(warnings, "skipping untitled assignment")
+			continue
+		}
+		a.Title = strings.TrimSpace(a.Title)
+		a.Week = lesson.Week
+		a.Section = lesson.SectionID
+		// IDs name submission directories, so keep them path-safe
+		a.ID = s.generateIDFromTitle(a.ID)
+		if a.ID == "" {
+			a.ID = fmt.Sprintf("week%d-%s", lesson.Week, s.generateIDFromTitle(a.Title))
+		}
+		if a.SubmissionType == "" {
+			a.SubmissionType = "file"
+		}
+		result = append(result, a)
+	}
+	return result, warnings
+}
+
+// assignmentCategoryWarning explains why an assignment in sectionID would be
+// left out of the weighted grade, or returns "" when its category is known
+func assignmentCategoryWarning(a *Assignment, sectionID string) string {
+	categories := sectionSyllabi[sectionID].AssessmentCategories
+	if len(categories) == 0 || assignmentCategory(a, categories) != uncategorizedCategory {
+		return ""
+	}
+	ids := make([]string, len(categories))
+	for i, c := range categories {
+		ids[i] = c.ID
+	}
+	if a.Category == "" {
+		return fmt.Sprintf("assignment %q has no category (one of %s) and is not weighted in the gradebook",
+			a.ID, strings.Join(ids, ", "))
+	}
+	return fmt.Sprintf("assignment %q: unknown category %q (expected one of %s); it is not weighted in the gradebook",
+		a.ID, a.Category, strings.Join(ids, ", "))
+}
+
+// resolveAssignmentDue computes DueAt from an explicit due date, or from
+// due_week/due_day against the course start_date. Without either the
+// assignment has no due date.
+func resolveAssignmentDue(a *Assignment, course Course) {
+	a.DueAt = nil
+
+	if due, ok := parseCourseDate(a.Due); ok {
+		if !strings.Contains(a.Due, "T") {
+			due = endOfDay(due)
+		}
+		a.DueAt = &due
+		return
+	}
+
+	if a.DueWeek == 0 && a.DueDay == 0 {
+		return
+	}
+	start, ok := parseCourseDate(course.StartDate)
+	if !ok {
+		return
+	}
+
+	week := a.DueWeek
+	if week == 0 {
+		week = a.Week
+	}
+	var due time.Time
+	if a.DueDay > 0 {
+		due = dayDate(start, week, a.DueDay, classDays(course.ClassDays))
+	} else {
+		due = weekStartDate(start, week).AddDate(0, 0, 6)
+	}
+	due = endOfDay(due)
+	a.DueAt = &due
+}
+
+// endOfDay is the last second of t's calendar day, which is not always 24
+// hours after midnight across a DST change
+func endOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 23, 59, 59, 0, t.Location())
+}
+
+// sortAssignments orders by due date; undated assignments go last by week
+func sortAssignments(assignments []*Assignment) {
+	sort.SliceStable(assignments, func(i, j int) bool {
+		a, b := assignments[i], assignments[j]
+		switch {
+		case a.DueAt != nil && b.DueAt != nil:
+			if !a.DueAt.Equal(*b.DueAt) {
+				return a.DueAt.Before(*b.DueAt)
+			}
+		case a.DueAt != nil:
+			return true
+		case b.DueAt != nil:
+			return false
+		}
+		if a.Week != b.Week {
+			return a.Week < b.Week
+		}
+		return a.ID < b.ID
+	})
+}
+
+// indexAssignments builds the assignment index from the lessons. Caller
+// must hold s.mutex.
+func (s *Server) indexAssignments(lessons map[int]*Lesson) map[string]*Assignment {
+	index := make(map[string]*Assignment)
+	for _, lesson := range lessons {
+		for _, a := range lesson.Assignments {
+			if existing, exists := index[a.ID]; exists {
+				slog.Warn("duplicate assignment id, keeping the first", "id", a.ID, "kept_week", existing.Week, "skipped_week", a.Week)
+				continue
+			}
+			if warning := assignmentCategoryWarning(a, a.Section); warning != "" {
+				slog.Warn("lesson warning", "week", a.Week, "warning", warning)
+			}
+			resolveAssignmentDue(a, s.course)
+			index[a.ID] = a
+		}
+	}
+	return index
+}
+
+func (s *Server) handleAssignments(w http.ResponseWriter, r *http.Request) {
+	s.mutex.RLock()
+	assignments := make([]*Assignment, 0, len(s.assignments))
+	for _, a := range s.assignments {
+		assignments = append(assignments, a)
+	}
+	s.mutex.RUnlock()
+
+	sortAssignments(assignments)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(assignments)
+}
+
+func (s *Server) handleSectionAssignments(w http.ResponseWriter, r *http.Request) {
+	sectionID := mux.Vars(r)["section"]
+
+	s.mutex.RLock()
+	_, exists := s.sections[sectionID]
+	assignments := make([]*Assignment, 0)
+	for _, a := range s.assignments {
+		if a.Section == sectionID {
+			assignments = append(assignments, a)
+		}
+	}
+	s.mutex.RUnlock()
+
+	if !exists {
+		http.Error(w, "Section not found", http.StatusNotFound)
+		return
+	}
+
+	sortAssignments(assignments)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(assignments)
+}
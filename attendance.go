@@ -0,0 +1,470 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	attendancePresent = "present"
+	attendanceAbsent  = "absent"
+	attendanceExcused = "excused"
+)
+
+// AttendanceRecord is one student's attendance for one Day of a lesson
+type AttendanceRecord struct {
+	Week       int       `json:"week"`
+	Day        int       `json:"day"`
+	Student    string    `json:"student"`
+	Status     string    `json:"status"`
+	Note       string    `json:"note,omitempty"`
+	RecordedBy string    `json:"recorded_by"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// AttendanceSummary counts statuses. Percent is present over present plus
+// absent, so excused days do not count against a student.
+type AttendanceSummary struct {
+	Present int      `json:"present"`
+	Absent  int      `json:"absent"`
+	Excused int      `json:"excused"`
+	Percent *float64 `json:"percent"`
+}
+
+func (a *AttendanceSummary) add(status string) {
+	switch status {
+	case attendancePresent:
+		a.Present++
+	case attendanceAbsent:
+		a.Absent++
+	case attendanceExcused:
+		a.Excused++
+	}
+}
+
+func (a *AttendanceSummary) finish() {
+	if counted := a.Present + a.Absent; counted > 0 {
+		percent := float64(int(float64(a.Present)/float64(counted)*10000)) / 100
+		a.Percent = &percent
+	}
+}
+
+type StudentAttendance struct {
+	Student string `json:"student"`
+	AttendanceSummary
+}
+
+// AttendanceSession is one Day of a lesson that attendance is taken for
+type AttendanceSession struct {
+	Week        int    `json:"week"`
+	SectionWeek int    `json:"section_week"`
+	Day         int    `json:"day"`
+	Title       string `json:"title"`
+	AttendanceSummary
+}
+
+// attendanceStore keeps records in attendance.json keyed by week/day/student
+type attendanceStore struct {
+	mutex   sync.RWMutex
+	store   *jsonStore
+	records map[string]*AttendanceRecord
+}
+
+func openAttendanceStore(path string) (*attendanceStore, error) {
+	a := &attendanceStore{records: make(map[string]*AttendanceRecord)}
+	store, err := openJSONStore(path, &a.records)
+	if err != nil {
+		return nil, err
+	}
+	a.store = store
+	return a, nil
+}
+
+// flush writes the store one last time before shutdown
+func (a *attendanceStore) flush() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.store.save(a.records)
+}
+
+func attendanceKey(week, day int, student string) string {
+	return fmt.Sprintf("%d/%d/%s", week, day, student)
+}
+
+// record saves a batch of records for one day; an empty status clears the
+// student's record. A failed save restores every key the batch touched.
+func (a *attendanceStore) record(records []*AttendanceRecord) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	previous := make(map[string]*AttendanceRecord) // nil for keys that did not exist
+	for _, record := range records {
+		key := attendanceKey(record.Week, record.Day, record.Student)
+		if _, saved := previous[key]; !saved {
+			previous[key] = a.records[key]
+		}
+		if record.Status == "" {
+			delete(a.records, key)
+		} else {
+			a.records[key] = record
+		}
+	}
+
+	if err := a.store.save(a.records); err != nil {
+		for key, record := range previous {
+			if record == nil {
+				delete(a.records, key)
+			} else {
+				a.records[key] = record
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// list returns copies of the records matching filter ordered by week, day
+// and student
+func (a *attendanceStore) list(filter func(*AttendanceRecord) bool) []AttendanceRecord {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	result := make([]AttendanceRecord, 0)
+	for _, record := range a.records {
+		if filter(record) {
+			result = append(result, *record)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Week != result[j].Week {
+			return result[i].Week < result[j].Week
+		}
+		if result[i].Day != result[j].Day {
+			return result[i].Day < result[j].Day
+		}
+		return result[i].Student < result[j].Student
+	})
+	return result
+}
+
+// lessonSessions lists the Day blocks attendance is taken for. A lesson
+// without Day headings is one session, day 1.
+func lessonSessions(lesson *Lesson) []LessonDay {
+	if len(lesson.Days) > 0 {
+		return lesson.Days
+	}
+	return []LessonDay{{Number: 1, Title: lesson.Title}}
+}
+
+// sectionSessions lists every session in the section in week and day order
+func (s *Server) sectionSessions(sectionID string) ([]AttendanceSession, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	section, exists := s.sections[sectionID]
+	if !exists {
+		return nil, false
+	}
+
+	sessions := make([]AttendanceSession, 0)
+	for _, lesson := range section.Lessons {
+		for _, day := range lessonSessions(lesson) {
+			sessions = append(sessions, AttendanceSession{
+				Week:        lesson.Week,
+				SectionWeek: lesson.Week - section.WeekStart + 1,
+				Day:         day.Number,
+				Title:       day.Title,
+			})
+		}
+	}
+	return sessions, true
+}
+
+// rosterStudents returns the usernames of every student account
+func (s *Server) rosterStudents() []string {
+	var students []string
+	for _, user := range s.accounts.listUsers() {
+		if user.Role == roleStudent {
+			students = append(students, user.Username)
+		}
+	}
+	return students
+}
+
+// sectionAttendance summarizes every session and every student seen in the
+// roster or the records
+func (s *Server) sectionAttendance(sessions []AttendanceSession) ([]AttendanceSession, []StudentAttendance, map[string]map[string]string) {
+	inSection := make(map[string]int)
+	for i, session := range sessions {
+		inSection[fmt.Sprintf("%d/%d", session.Week, session.Day)] = i
+	}
+
+	records := s.attendance.list(func(record *AttendanceRecord) bool {
+		_, ok := inSection[fmt.Sprintf("%d/%d", record.Week, record.Day)]
+		return ok
+	})
+
+	byStudent := make(map[string]*StudentAttendance)
+	statuses := make(map[string]map[string]string) // student -> "week/day" -> status
+	for _, student := range s.rosterStudents() {
+		byStudent[student] = &StudentAttendance{Student: student}
+		statuses[student] = make(map[string]string)
+	}
+
+	for _, record := range records {
+		key := fmt.Sprintf("%d/%d", record.Week, record.Day)
+		sessions[inSection[key]].add(record.Status)
+
+		if byStudent[record.Student] == nil {
+			byStudent[record.Student] = &StudentAttendance{Student: record.Student}
+			statuses[record.Student] = make(map[string]string)
+		}
+		byStudent[record.Student].add(record.Status)
+		statuses[record.Student][key] = record.Status
+	}
+
+	for i := range sessions {
+		sessions[i].finish()
+	}
+	students := make([]StudentAttendance, 0, len(byStudent))
+	for _, summary := range byStudent {
+		summary.finish()
+		students = append(students, *summary)
+	}
+	sort.Slice(students, func(i, j int) bool {
+		return students[i].Student < students[j].Student
+	})
+
+	return sessions, students, statuses
+}
+
+// attendanceDay resolves /sections/{section}/week/{week}/days/{day}
+func (s *Server) attendanceDay(w http.ResponseWriter, r *http.Request) (*Lesson, LessonDay, bool) {
+	lesson, ok := s.lessonForSectionWeek(w, r)
+	if !ok {
+		return nil, LessonDay{}, false
+	}
+
+	number, err := strconv.Atoi(mux.Vars(r)["day"])
+	if err == nil {
+		for _, day := range lessonSessions(lesson) {
+			if day.Number == number {
+				return lesson, day, true
+			}
+		}
+	}
+	http.Error(w, "Day not found in lesson", http.StatusNotFound)
+	return nil, LessonDay{}, false
+}
+
+func (s *Server) handleDayAttendance(w http.ResponseWriter, r *http.Request) {
+	lesson, day, ok := s.attendanceDay(w, r)
+	if !ok {
+		return
+	}
+
+	records := s.attendance.list(func(record *AttendanceRecord) bool {
+		return record.Week == lesson.Week && record.Day == day.Number
+	})
+
+	response := struct {
+		Week    int                `json:"week"`
+		Section string             `json:"section"`
+		Day     LessonDay          `json:"day"`
+		Roster  []string           `json:"roster"`
+		Records []AttendanceRecord `json:"records"`
+	}{
+		Week:    lesson.Week,
+		Section: lesson.SectionID,
+		Day:     day,
+		Roster:  s.rosterStudents(),
+		Records: records,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+type attendanceRequest struct {
+	Records []struct {
+		Student string `json:"student"`
+		Status  string `json:"status"` // present, absent, excused, or empty to clear
+		Note    string `json:"note"`
+	} `json:"records"`
+}
+
+func (s *Server) handleRecordAttendance(w http.ResponseWriter, r *http.Request) {
+	lesson, day, ok := s.attendanceDay(w, r)
+	if !ok {
+		return
+	}
+
+	var req attendanceRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	roster := make(map[string]bool)
+	for _, student := range s.rosterStudents() {
+		roster[student] = true
+	}
+
+	now := time.Now()
+	recordedBy := requestUser(r).Username
+	records := make([]*AttendanceRecord, 0, len(req.Records))
+	for _, entry := range req.Records {
+		switch entry.Status {
+		case attendancePresent, attendanceAbsent, attendanceExcused, "":
+		default:
+			http.Error(w, fmt.Sprintf("Invalid status %q for %s", entry.Status, entry.Student), http.StatusBadRequest)
+			return
+		}
+		if !roster[entry.Student] {
+			http.Error(w, fmt.Sprintf("Unknown student %q", entry.Student), http.StatusBadRequest)
+			return
+		}
+		records = append(records, &AttendanceRecord{
+			Week:       lesson.Week,
+			Day:        day.Number,
+			Student:    entry.Student,
+			Status:     entry.Status,
+			Note:       entry.Note,
+			RecordedBy: recordedBy,
+			RecordedAt: now,
+		})
+	}
+
+	if err := s.attendance.record(records); err != nil {
+		slog.Error("failed to save attendance", "error", err)
+		http.Error(w, "Failed to save attendance", http.StatusInternalServerError)
+		return
+	}
+
+	s.handleDayAttendance(w, r)
+}
+
+func (s *Server) handleSectionAttendance(w http.ResponseWriter, r *http.Request) {
+	sectionID := mux.Vars(r)["section"]
+	sessions, exists := s.sectionSessions(sectionID)
+	if !exists {
+		http.Error(w, "Section not found", http.StatusNotFound)
+		return
+	}
+
+	sessions, students, _ := s.sectionAttendance(sessions)
+
+	var total AttendanceSummary
+	for _, student := range students {
+		total.Present += student.Present
+		total.Absent += student.Absent
+		total.Excused += student.Excused
+	}
+	total.finish()
+
+	response := struct {
+		Section  string              `json:"section"`
+		Summary  AttendanceSummary   `json:"summary"`
+		Sessions []AttendanceSession `json:"sessions"`
+		Students []StudentAttendance `json:"students"`
+	}{
+		Section:  sectionID,
+		Summary:  total,
+		Sessions: sessions,
+		Students: students,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (s *Server) handleStudentAttendance(w http.ResponseWriter, r *http.Request) {
+	sectionID := mux.Vars(r)["section"]
+	studentID := mux.Vars(r)["student"]
+	if !selfOrInstructor(r, studentID) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	sessions, exists := s.sectionSessions(sectionID)
+	if !exists {
+		http.Error(w, "Section not found", http.StatusNotFound)
+		return
+	}
+
+	inSection := make(map[string]bool)
+	for _, session := range sessions {
+		inSection[fmt.Sprintf("%d/%d", session.Week, session.Day)] = true
+	}
+	records := s.attendance.list(func(record *AttendanceRecord) bool {
+		return record.Student == studentID && inSection[fmt.Sprintf("%d/%d", record.Week, record.Day)]
+	})
+
+	summary := StudentAttendance{Student: studentID}
+	for _, record := range records {
+		summary.add(record.Status)
+	}
+	summary.finish()
+
+	response := struct {
+		StudentAttendance
+		Section string             `json:"section"`
+		Records []AttendanceRecord `json:"records"`
+	}{
+		StudentAttendance: summary,
+		Section:           sectionID,
+		Records:           records,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleAttendanceCSV exports one row per student with a P/A/E column per
+// session followed by the totals and percentage
+func (s *Server) handleAttendanceCSV(w http.ResponseWriter, r *http.Request) {
+	sectionID := mux.Vars(r)["section"]
+	sessions, exists := s.sectionSessions(sectionID)
+	if !exists {
+		http.Error(w, "Section not found", http.StatusNotFound)
+		return
+	}
+
+	sessions, students, statuses := s.sectionAttendance(sessions)
+
+	header := []string{"student_id"}
+	for _, session := range sessions {
+		header = append(header, fmt.Sprintf("week%d-day%d", session.SectionWeek, session.Day))
+	}
+	header = append(header, "present", "absent", "excused", "percent")
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-attendance.csv"`, sectionID))
+
+	out := csv.NewWriter(w)
+	out.Write(header)
+
+	codes := map[string]string{attendancePresent: "P", attendanceAbsent: "A", attendanceExcused: "E"}
+	for _, student := range students {
+		row := []string{student.Student}
+		for _, session := range sessions {
+			row = append(row, codes[statuses[student.Student][fmt.Sprintf("%d/%d", session.Week, session.Day)]])
+		}
+		percent := ""
+		if student.Percent != nil {
+			percent = strconv.FormatFloat(*student.Percent, 'f', 2, 64)
+		}
+		row = append(row, strconv.Itoa(student.Present), strconv.Itoa(student.Absent), strconv.Itoa(student.Excused), percent)
+		out.Write(row)
+	}
+	out.Flush()
+}
@@ -0,0 +1,646 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	roleInstructor = "instructor"
+	roleStudent    = "student"
+
+	sessionCookieName = "course_session"
+	csrfHeaderName    = "X-CSRF-Token"
+	studentHeaderName = "X-Student-ID"
+	sessionLifetime   = 12 * time.Hour
+	minPasswordLength = 8
+)
+
+// User is a local account. Usernames double as student IDs.
+type User struct {
+	Username     string    `json:"username"`
+	Name         string    `json:"name"`
+	Role         string    `json:"role"`
+	PasswordHash string    `json:"password_hash"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// PublicUser is a User without the password hash
+type PublicUser struct {
+	Username  string    `json:"username"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (u *User) public() PublicUser {
+	return PublicUser{Username: u.Username, Name: u.Name, Role: u.Role, CreatedAt: u.CreatedAt}
+}
+
+// Session is keyed by the SHA-256 of the cookie token so a leaked store
+// cannot be replayed as cookies
+type Session struct {
+	Username  string    `json:"username"`
+	CSRFToken string    `json:"csrf_token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// accountStore keeps users in accounts.json and sessions in sessions.json
+type accountStore struct {
+	mutex         sync.RWMutex
+	users         map[string]*User
+	sessions      map[string]*Session
+	usersStore    *jsonStore
+	sessionsStore *jsonStore
+}
+
+func openAccountStore(dir string) (*accountStore, error) {
+	a := &accountStore{
+		users:    make(map[string]*User),
+		sessions: make(map[string]*Session),
+	}
+
+	usersStore, err := openJSONStore(filepath.Join(dir, "accounts.json"), &a.users)
+	if err != nil {
+		return nil, err
+	}
+	sessionsStore, err := openJSONStore(filepath.Join(dir, "sessions.json"), &a.sessions)
+	if err != nil {
+		return nil, err
+	}
+	a.usersStore, a.sessionsStore = usersStore, sessionsStore
+
+	now := time.Now()
+	for key, session := range a.sessions {
+		if now.After(session.ExpiresAt) || a.users[session.Username] == nil {
+			delete(a.sessions, key)
+		}
+	}
+	return a, nil
+}
+
+// flush writes users and sessions one last time before shutdown
+func (a *accountStore) flush() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if err := a.usersStore.save(a.users); err != nil {
+		return err
+	}
+	return a.sessionsStore.save(a.sessions)
+}
+
+func randomToken(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func validRole(role string) bool {
+	return role == roleInstructor || role == roleStudent
+}
+
+// createUser adds a new account; it fails if the username exists
+func (a *accountStore) createUser(username, name, role, password string) (*User, error) {
+	if !studentIDPattern.MatchString(username) {
+		return nil, fmt.Errorf("invalid username %q", username)
+	}
+	if !validRole(role) {
+		return nil, fmt.Errorf("invalid role %q", role)
+	}
+	if len(password) < minPasswordLength {
+		return nil, fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if _, exists := a.users[username]; exists {
+		return nil, fmt.Errorf("user %q already exists", username)
+	}
+	user := &User{Username: username, Name: name, Role: role, PasswordHash: string(hash), CreatedAt: time.Now()}
+	a.users[username] = user
+	if err := a.usersStore.save(a.users); err != nil {
+		delete(a.users, username)
+		return nil, err
+	}
+	return user, nil
+}
+
+func (a *accountStore) setPassword(username, password string) error {
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	user, exists := a.users[username]
+	if !exists {
+		return fmt.Errorf("user %q not found", username)
+	}
+	user.PasswordHash = string(hash)
+	return a.usersStore.save(a.users)
+}
+
+func (a *accountStore) deleteUser(username string) (bool, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if _, exists := a.users[username]; !exists {
+		return false, nil
+	}
+	delete(a.users, username)
+	for key, session := range a.sessions {
+		if session.Username == username {
+			delete(a.sessions, key)
+		}
+	}
+	if err := a.sessionsStore.save(a.sessions); err != nil {
+		return true, err
+	}
+	return true, a.usersStore.save(a.users)
+}
+
+func (a *accountStore) listUsers() []PublicUser {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	users := make([]PublicUser, 0, len(a.users))
+	for _, user := range a.users {
+		users = append(users, user.public())
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+	return users
+}
+
+func (a *accountStore) hasInstructor() bool {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	for _, user := range a.users {
+		if user.Role == roleInstructor {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticate checks the password; unknown users still pay a bcrypt
+// comparison so response time does not reveal which usernames exist
+func (a *accountStore) authenticate(username, password string) (*User, bool) {
+	a.mutex.RLock()
+	user, exists := a.users[username]
+	a.mutex.RUnlock()
+
+	hash := dummyPasswordHash
+	if exists {
+		hash = []byte(user.PasswordHash)
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil || !exists {
+		return nil, false
+	}
+	return user, true
+}
+
+// newSession returns the cookie token for a fresh session
+func (a *accountStore) newSession(username string) (string, *Session, error) {
+	token := randomToken(32)
+	session := &Session{Username: username, CSRFToken: randomToken(32), ExpiresAt: time.Now().Add(sessionLifetime)}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	now := time.Now()
+	for key, existing := range a.sessions {
+		if now.After(existing.ExpiresAt) {
+			delete(a.sessions, key)
+		}
+	}
+	a.sessions[hashToken(token)] = session
+	return token, session, a.sessionsStore.save(a.sessions)
+}
+
+func (a *accountStore) session(token string) (*Session, *User, bool) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	session, exists := a.sessions[hashToken(token)]
+	if !exists || time.Now().After(session.ExpiresAt) {
+		return nil, nil, false
+	}
+	user, exists := a.users[session.Username]
+	if !exists {
+		return nil, nil, false
+	}
+	return session, user, true
+}
+
+func (a *accountStore) endSession(token string) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	delete(a.sessions, hashToken(token))
+	return a.sessionsStore.save(a.sessions)
+}
+
+// bootstrapInstructor creates an "instructor" account on first start and
+// writes its generated password next to the stores
+func (a *accountStore) bootstrapInstructor(dataDir string) error {
+	if a.hasInstructor() {
+		return nil
+	}
+
+	password := randomToken(12)
+	if _, err := a.createUser("instructor", "Course Instructor", roleInstructor, password); err != nil {
+		return err
+	}
+
+	path := filepath.Join(dataDir, "initial-instructor-password.txt")
+	if err := os.WriteFile(path, []byte("username: instructor\npassword: "+password+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write initial password: %w", err)
+	}
+	slog.Info("created instructor account; initial password written to file", "file", path)
+	return nil
+}
+
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("not-a-real-password"), bcrypt.DefaultCost)
+
+type contextKey string
+
+const (
+	contextUser    contextKey = "user"
+	contextSession contextKey = "session"
+)
+
+func requestUser(r *http.Request) *User {
+	user, _ := r.Context().Value(contextUser).(*User)
+	return user
+}
+
+func requestSession(r *http.Request) *Session {
+	session, _ := r.Context().Value(contextSession).(*Session)
+	return session
+}
+
+func isUnsafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// sessionMiddleware attaches the session user to the request context and
+// enforces the CSRF token on state-changing requests made with a session
+func (s *Server) sessionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(sessionCookieName)
+		if err != nil || s.accounts == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		session, user, ok := s.accounts.session(cookie.Value)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if isUnsafeMethod(r.Method) {
+			token := r.Header.Get(csrfHeaderName)
+			if subtle.ConstantTimeCompare([]byte(token), []byte(session.CSRFToken)) != 1 {
+				http.Error(w, "Missing or invalid CSRF token", http.StatusForbidden)
+				return
+			}
+		}
+
+		if info := requestLogInfo(r); info != nil {
+			info.user = user.Username
+		}
+		ctx := context.WithValue(r.Context(), contextUser, user)
+		ctx = context.WithValue(ctx, contextSession, session)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requireRole only lets signed-in users with one of the roles through.
+// With no roles any signed-in user is accepted.
+func (s *Server) requireRole(roles ...string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := requestUser(r)
+			if user == nil {
+				http.Error(w, "Authentication required", http.StatusUnauthorized)
+				return
+			}
+			if len(roles) > 0 {
+				allowed := false
+				for _, role := range roles {
+					allowed = allowed || user.Role == role
+				}
+				if !allowed {
+					http.Error(w, "Forbidden", http.StatusForbidden)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// readAccess guards read-only lesson routes, which stay public unless
+// publicContent is turned off
+func (s *Server) readAccess(h http.HandlerFunc) http.Handler {
+	if s.publicContent {
+		return h
+	}
+	return s.requireRole()(h)
+}
+
+// instructorOnly wraps a handler with the instructor role check
+func (s *Server) instructorOnly(h http.HandlerFunc) http.Handler {
+	return s.requireRole(roleInstructor)(h)
+}
+
+// signedIn wraps a handler so any signed-in user may call it
+func (s *Server) signedIn(h http.HandlerFunc) http.Handler {
+	return s.requireRole()(h)
+}
+
+// actingStudent is the student a request acts for: students always act
+// as themselves, instructors may name a student, anonymous requests none
+func (s *Server) actingStudent(r *http.Request, claimed string) string {
+	user := requestUser(r)
+	if user == nil {
+		return ""
+	}
+	if user.Role == roleStudent {
+		return user.Username
+	}
+	if claimed != "" {
+		return strings.TrimSpace(claimed)
+	}
+	if id := strings.TrimSpace(r.Header.Get(studentHeaderName)); id != "" {
+		return id
+	}
+	return strings.TrimSpace(r.URL.Query().Get("student"))
+}
+
+// selfOrInstructor allows a student to read their own records only
+func selfOrInstructor(r *http.Request, studentID string) bool {
+	user := requestUser(r)
+	return user != nil && (user.Role == roleInstructor || user.Username == studentID)
+}
+
+// setSessionCookie sets the session cookie SameSite=Lax, which browsers only
+// send to same-site origins. With an explicit CORS allowlist over HTTPS it is
+// SameSite=None so cross-site front ends can use the session too; browsers
+// reject SameSite=None without Secure, so plain HTTP stays same-site only.
+func (s *Server) setSessionCookie(w http.ResponseWriter, r *http.Request, token string, expires time.Time) {
+	sameSite := http.SameSiteLaxMode
+	if r.TLS != nil && s.corsCredentials() {
+		sameSite = http.SameSiteNoneMode
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: sameSite,
+	})
+}
+
+type loginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var req loginRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := s.accounts.authenticate(strings.TrimSpace(req.Username), req.Password)
+	if !ok {
+		slog.Warn("failed login", "username", req.Username, "remote", r.RemoteAddr)
+		http.Error(w, "Invalid username or password", http.StatusUnauthorized)
+		return
+	}
+
+	token, session, err := s.accounts.newSession(user.Username)
+	if err != nil {
+		slog.Error("failed to create session", "username", user.Username, "error", err)
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+	s.setSessionCookie(w, r, token, session.ExpiresAt)
+
+	response := struct {
+		User      PublicUser `json:"user"`
+		CSRFToken string     `json:"csrf_token"`
+		ExpiresAt time.Time  `json:"expires_at"`
+	}{user.public(), session.CSRFToken, session.ExpiresAt}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		if err := s.accounts.endSession(cookie.Value); err != nil {
+			slog.Error("failed to end session", "error", err)
+		}
+	}
+	s.setSessionCookie(w, r, "", time.Unix(0, 0))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
+	user, session := requestUser(r), requestSession(r)
+
+	response := struct {
+		User      PublicUser `json:"user"`
+		CSRFToken string     `json:"csrf_token"`
+		ExpiresAt time.Time  `json:"expires_at"`
+	}{user.public(), session.CSRFToken, session.ExpiresAt}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+type passwordRequest struct {
+	Current string `json:"current_password"`
+	New     string `json:"new_password"`
+}
+
+func (s *Server) handleChangePassword(w http.ResponseWriter, r *http.Request) {
+	user := requestUser(r)
+
+	var req passwordRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if _, ok := s.accounts.authenticate(user.Username, req.Current); !ok {
+		http.Error(w, "Current password is incorrect", http.StatusForbidden)
+		return
+	}
+	if err := s.accounts.setPassword(user.Username, req.New); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleListUsers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.accounts.listUsers())
+}
+
+type createUserRequest struct {
+	Username string `json:"username"`
+	Name     string `json:"name"`
+	Role     string `json:"role"`
+	Password string `json:"password"`
+}
+
+func (s *Server) handleCreateUser(w http.ResponseWriter, r *http.Request) {
+	var req createUserRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if req.Role == "" {
+		req.Role = roleStudent
+	}
+
+	user, err := s.accounts.createUser(strings.TrimSpace(req.Username), strings.TrimSpace(req.Name), req.Role, req.Password)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(user.public())
+}
+
+func (s *Server) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	if current := requestUser(r); current != nil && current.Username == username {
+		http.Error(w, "Cannot delete your own account", http.StatusBadRequest)
+		return
+	}
+
+	removed, err := s.accounts.deleteUser(username)
+	if err != nil {
+		slog.Error("failed to delete user", "username", username, "error", err)
+		http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type rosterResult struct {
+	Username string `json:"username"`
+	Name     string `json:"name"`
+	Role     string `json:"role"`
+	Password string `json:"password,omitempty"` // Only set when generated
+	Status   string `json:"status"`             // "created", "exists" or the error
+}
+
+// handleImportRoster creates accounts from CSV rows of
+// username,name[,role[,password]]. Missing passwords are generated and
+// returned once in the response.
+func (s *Server) handleImportRoster(w http.ResponseWriter, r *http.Request) {
+	reader := csv.NewReader(http.MaxBytesReader(w, r.Body, 1<<20))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	results := make([]rosterResult, 0)
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid CSV at line %d: %v", line, err), http.StatusBadRequest)
+			return
+		}
+		if len(record) < 2 || (line == 1 && strings.EqualFold(record[0], "username")) {
+			continue
+		}
+
+		result := rosterResult{Username: strings.TrimSpace(record[0]), Name: strings.TrimSpace(record[1]), Role: roleStudent}
+		if len(record) > 2 && strings.TrimSpace(record[2]) != "" {
+			result.Role = strings.ToLower(strings.TrimSpace(record[2]))
+		}
+		password := ""
+		if len(record) > 3 {
+			password = record[3]
+		}
+		if password == "" {
+			password = randomToken(9)
+			result.Password = password
+		}
+
+		if _, err := s.accounts.createUser(result.Username, result.Name, result.Role, password); err != nil {
+			result.Password = ""
+			result.Status = err.Error()
+			if strings.Contains(err.Error(), "already exists") {
+				result.Status = "exists"
+			}
+		} else {
+			result.Status = "created"
+		}
+		results = append(results, result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
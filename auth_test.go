@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPasswordsAreHashed(t *testing.T) {
+	ts := newTestServer(t, nil)
+
+	user, _ := ts.accounts.authenticate("student1", testPassword)
+	if user == nil {
+		t.Fatal("correct password rejected")
+	}
+	if user.PasswordHash == testPassword || !strings.HasPrefix(user.PasswordHash, "$2") {
+		t.Errorf("password stored as %q, want a bcrypt hash", user.PasswordHash)
+	}
+	if _, ok := ts.accounts.authenticate("student1", "wrong-password"); ok {
+		t.Error("wrong password accepted")
+	}
+	if _, ok := ts.accounts.authenticate("nobody", testPassword); ok {
+		t.Error("unknown user accepted")
+	}
+	if _, err := ts.accounts.createUser("student2", "", roleStudent, "short"); err == nil {
+		t.Error("password shorter than the minimum accepted")
+	}
+}
+
+func TestExpiredSessionIsRejected(t *testing.T) {
+	ts := newTestServer(t, nil)
+	client := ts.login("student1")
+
+	if rec := ts.do(client, http.MethodGet, "/api/auth/me", nil, nil); rec.Code != http.StatusOK {
+		t.Fatalf("fresh session: status %d", rec.Code)
+	}
+
+	ts.accounts.mutex.Lock()
+	ts.accounts.sessions[hashToken(client.cookie.Value)].ExpiresAt = time.Now().Add(-time.Minute)
+	ts.accounts.mutex.Unlock()
+
+	if rec := ts.do(client, http.MethodGet, "/api/auth/me", nil, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expired session: status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestCSRFTokenRequiredForUnsafeMethods(t *testing.T) {
+	ts := newTestServer(t, nil)
+	client := ts.login("student1")
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"missing", "", http.StatusForbidden},
+		{"wrong", "not-the-token", http.StatusForbidden},
+		{"valid", client.csrf, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ts.do(client, http.MethodPost, "/api/auth/logout", nil, map[string]string{csrfHeaderName: tt.token})
+			if rec.Code != tt.want {
+				t.Errorf("status %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouteGuards(t *testing.T) {
+	ts := newTestServer(t, nil)
+	student := ts.login("student1")
+	instructor := ts.login("instructor")
+
+	tests := []struct {
+		name   string
+		path   string
+		client *testClient
+		want   int
+	}{
+		{"instructorOnly anonymous", "/api/users", nil, http.StatusUnauthorized},
+		{"instructorOnly student", "/api/users", student, http.StatusForbidden},
+		{"instructorOnly instructor", "/api/users", instructor, http.StatusOK},
+		{"signedIn anonymous", "/api/progress", nil, http.StatusUnauthorized},
+		{"signedIn student", "/api/progress", student, http.StatusOK},
+		{"readAccess anonymous", "/api/lessons", nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := ts.do(tt.client, http.MethodGet, tt.path, nil, nil); rec.Code != tt.want {
+				t.Errorf("status %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+
+	ts.publicContent = false
+	ts.handler = ts.setupRoutes()
+	if rec := ts.do(nil, http.MethodGet, "/api/lessons", nil, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("readAccess anonymous without public content: status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if rec := ts.do(student, http.MethodGet, "/api/lessons", nil, nil); rec.Code != http.StatusOK {
+		t.Errorf("readAccess student without public content: status %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestActingStudent(t *testing.T) {
+	s := newIndexServer(t.TempDir())
+	student := &User{Username: "student1", Role: roleStudent}
+	instructor := &User{Username: "instructor", Role: roleInstructor}
+
+	tests := []struct {
+		name    string
+		user    *User
+		claimed string
+		header  string
+		want    string
+	}{
+		{"anonymous", nil, "student2", "student2", ""},
+		{"student acts as themselves", student, "student2", "student2", "student1"},
+		{"instructor names a student", instructor, "student2", "", "student2"},
+		{"instructor uses the header", instructor, "", "student3", "student3"},
+		{"claimed wins over the header", instructor, "student2", "student3", "student2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(studentHeaderName, tt.header)
+			}
+			if tt.user != nil {
+				req = req.WithContext(context.WithValue(req.Context(), contextUser, tt.user))
+			}
+			if got := s.actingStudent(req, tt.claimed); got != tt.want {
+				t.Errorf("actingStudent = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
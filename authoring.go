@@ -0,0 +1,424 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gopkg.in/yaml.v3"
+)
+
+const maxLessonSize = 2 << 20
+
+// lessonChecksum identifies one version of a lesson file so the index can
+// tell when it has fallen behind the disk
+func lessonChecksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
+
+// lessonETag is the ETag GET /sections/{section}/week/{week} serves for
+// the lesson, which clients send back in If-Match
+func lessonETag(lesson *Lesson) string {
+	data, _ := json.Marshal(lesson)
+	return contentETag(append(data, '\n'))
+}
+
+// lessonWriteRequest is the JSON form of an authoring request. On update,
+// omitted fields keep their current value.
+type lessonWriteRequest struct {
+	Title       string  `json:"title"`
+	Description *string `json:"description"`
+	Due         *string `json:"due"`
+	Content     *string `json:"content"`
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+// buildLessonFile renders frontmatter and body for a new lesson
+func buildLessonFile(req lessonWriteRequest, week int) ([]byte, error) {
+	metadata := LessonMetadata{
+		Title:       req.Title,
+		Description: stringValue(req.Description),
+		Week:        week,
+		Due:         stringValue(req.Due),
+	}
+	frontmatter, err := yaml.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode frontmatter: %w", err)
+	}
+	return []byte("---\n" + string(frontmatter) + "---\n\n" + strings.TrimSpace(stringValue(req.Content)) + "\n"), nil
+}
+
+// mergeLessonFile applies a JSON update to an existing lesson file. Only the
+// requested fields change; every other frontmatter key (assignments,
+// quizzes, objectives and anything else), its order and comments are kept.
+func mergeLessonFile(existing []byte, req lessonWriteRequest, week int) ([]byte, error) {
+	var doc yaml.Node
+	found, err := decodeFrontmatter(string(existing), &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	body := string(existing)
+	if found {
+		_, body, _ = cutFrontmatter(body)
+	}
+
+	var mapping *yaml.Node
+	switch {
+	case doc.Kind == 0:
+		mapping = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{mapping}}
+	case doc.Kind == yaml.DocumentNode && len(doc.Content) == 1 && doc.Content[0].Kind == yaml.MappingNode:
+		mapping = doc.Content[0]
+	default:
+		return nil, fmt.Errorf("frontmatter is not a YAML mapping")
+	}
+
+	setFrontmatterValue(mapping, "title", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: req.Title})
+	setFrontmatterValue(mapping, "week", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(week)})
+	for key, value := range map[string]*string{"description": req.Description, "due": req.Due} {
+		switch {
+		case value == nil:
+		case *value == "":
+			setFrontmatterValue(mapping, key, nil)
+		default:
+			setFrontmatterValue(mapping, key, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: *value})
+		}
+	}
+	if req.Content != nil {
+		body = strings.TrimSpace(*req.Content)
+	}
+
+	var frontmatter bytes.Buffer
+	encoder := yaml.NewEncoder(&frontmatter)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to encode frontmatter: %w", err)
+	}
+	return []byte("---\n" + frontmatter.String() + "---\n\n" + body + "\n"), nil
+}
+
+// setFrontmatterValue replaces key's value in a YAML mapping, appending the
+// key when it is missing and removing it when value is nil
+func setFrontmatterValue(mapping *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != key {
+			continue
+		}
+		if value == nil {
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+			return
+		}
+		value.LineComment = mapping.Content[i+1].LineComment
+		mapping.Content[i+1] = value
+		return
+	}
+	if value != nil {
+		mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+	}
+}
+
+// prepareRawLesson checks raw Markdown for a frontmatter week matching the
+// URL, adding frontmatter when the file has none
+func prepareRawLesson(raw []byte, week int) ([]byte, error) {
+	metadata, _, found, err := splitFrontmatter(string(raw))
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return []byte(fmt.Sprintf("---\nweek: %d\n---\n\n", week) + string(raw)), nil
+	}
+	if metadata.Week != week {
+		return nil, fmt.Errorf("frontmatter week %d does not match week %d", metadata.Week, week)
+	}
+	return raw, nil
+}
+
+// lessonWrite is a parsed authoring request: either a complete raw file or
+// JSON fields to render into one
+type lessonWrite struct {
+	raw  []byte
+	json *lessonWriteRequest
+}
+
+// render returns the file to write. existing is the current file when
+// updating and nil when creating.
+func (l lessonWrite) render(existing []byte, week int) ([]byte, error) {
+	switch {
+	case l.json == nil:
+		return l.raw, nil
+	case existing == nil:
+		return buildLessonFile(*l.json, week)
+	default:
+		return mergeLessonFile(existing, *l.json, week)
+	}
+}
+
+// readLessonRequest accepts either JSON fields or a raw text/markdown file
+func readLessonRequest(r *http.Request, week int) (lessonWrite, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxLessonSize+1))
+	if err != nil {
+		return lessonWrite{}, fmt.Errorf("failed to read body: %w", err)
+	}
+	if len(body) > maxLessonSize {
+		return lessonWrite{}, fmt.Errorf("lesson exceeds %d MB", maxLessonSize>>20)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "text/markdown" || mediaType == "text/plain" {
+		raw, err := prepareRawLesson(body, week)
+		return lessonWrite{raw: raw}, err
+	}
+
+	var req lessonWriteRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return lessonWrite{}, fmt.Errorf("invalid JSON body: %w", err)
+	}
+	if strings.TrimSpace(req.Title) == "" {
+		return lessonWrite{}, fmt.Errorf("title is required")
+	}
+	return lessonWrite{json: &req}, nil
+}
+
+// authoringTarget resolves the section and global week from the URL and
+// returns the path of the existing lesson file or where a new one goes
+func (s *Server) authoringTarget(w http.ResponseWriter, r *http.Request) (string, int, bool) {
+	vars := mux.Vars(r)
+	sectionID := vars["section"]
+
+	week, err := strconv.Atoi(vars["week"])
+	if err != nil || week < 1 {
+		http.Error(w, "Invalid week number", http.StatusBadRequest)
+		return "", 0, false
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	section, exists := s.sections[sectionID]
+	if !exists {
+		http.Error(w, "Section not found", http.StatusNotFound)
+		return "", 0, false
+	}
+
+	globalWeek := section.WeekStart + week - 1
+	if globalWeek > section.WeekEnd {
+		http.Error(w, "Week outside section range", http.StatusBadRequest)
+		return "", 0, false
+	}
+
+	if lesson, exists := s.lessons[globalWeek]; exists {
+		return lesson.FilePath, globalWeek, true
+	}
+	return filepath.Join(s.lessonsDir, sectionID, fmt.Sprintf("week%d.md", globalWeek)), globalWeek, true
+}
+
+// writeLessonResponse rescans so the index reflects the write immediately
+// and returns the stored lesson with its new ETag
+func (s *Server) writeLessonResponse(w http.ResponseWriter, week int, status int) {
+	if err := s.scanLessons(); err != nil {
+		slog.Error("failed to rescan lessons after write", "week", week, "error", err)
+	}
+
+	s.mutex.RLock()
+	lesson, exists := s.lessons[week]
+	s.mutex.RUnlock()
+
+	if !exists {
+		http.Error(w, "Lesson written but could not be indexed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", lessonETag(lesson))
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(lesson)
+}
+
+// checkLessonPrecondition reads the lesson file and checks If-Match against
+// the indexed lesson, rescanning first if the file changed since the last
+// scan. Caller must hold s.authoringMutex.
+func (s *Server) checkLessonPrecondition(w http.ResponseWriter, path string, week int, ifMatch string) ([]byte, bool) {
+	current, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		http.Error(w, "Lesson not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		slog.Error("failed to read lesson", "file", path, "error", err)
+		http.Error(w, "Failed to read lesson", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	s.mutex.RLock()
+	lesson, exists := s.lessons[week]
+	s.mutex.RUnlock()
+
+	if !exists || lesson.Checksum != lessonChecksum(current) {
+		if err := s.scanLessons(); err != nil {
+			slog.Error("failed to rescan lessons", "error", err)
+		}
+		s.mutex.RLock()
+		lesson, exists = s.lessons[week]
+		s.mutex.RUnlock()
+	}
+	if !exists {
+		http.Error(w, "Lesson not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	etag := lessonETag(lesson)
+	if !etagMatches(ifMatch, etag) {
+		w.Header().Set("ETag", etag)
+		http.Error(w, "Lesson was modified since it was read", http.StatusPreconditionFailed)
+		return nil, false
+	}
+	return current, true
+}
+
+func (s *Server) handleCreateLesson(w http.ResponseWriter, r *http.Request) {
+	path, week, ok := s.authoringTarget(w, r)
+	if !ok {
+		return
+	}
+
+	write, err := readLessonRequest(r, week)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := write.render(nil, week)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.authoringMutex.Lock()
+	defer s.authoringMutex.Unlock()
+
+	if _, err := os.Stat(path); err == nil {
+		http.Error(w, "Lesson already exists", http.StatusConflict)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		slog.Error("failed to create section directory", "dir", filepath.Dir(path), "error", err)
+		http.Error(w, "Failed to create section directory", http.StatusInternalServerError)
+		return
+	}
+	if err := writeFileAtomic(path, data, 0644); err != nil {
+		slog.Error("failed to write lesson", "file", path, "error", err)
+		http.Error(w, "Failed to write lesson", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("lesson created", "week", week, "file", path)
+	s.writeLessonResponse(w, week, http.StatusCreated)
+}
+
+func (s *Server) handleUpdateLesson(w http.ResponseWriter, r *http.Request) {
+	path, week, ok := s.authoringTarget(w, r)
+	if !ok {
+		return
+	}
+
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		http.Error(w, "If-Match header required", http.StatusPreconditionRequired)
+		return
+	}
+
+	write, err := readLessonRequest(r, week)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.authoringMutex.Lock()
+	defer s.authoringMutex.Unlock()
+
+	current, ok := s.checkLessonPrecondition(w, path, week, ifMatch)
+	if !ok {
+		return
+	}
+	// Refuse rather than drop metadata the request did not mention
+	data, err := write.render(current, week)
+	if err != nil {
+		http.Error(w, "Cannot merge into the existing lesson frontmatter: "+err.Error(), http.StatusConflict)
+		return
+	}
+
+	if err := writeFileAtomic(path, data, 0644); err != nil {
+		slog.Error("failed to write lesson", "file", path, "error", err)
+		http.Error(w, "Failed to write lesson", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("lesson updated", "week", week, "file", path)
+	s.writeLessonResponse(w, week, http.StatusOK)
+}
+
+// handleDeleteLesson moves the lesson file into the data directory rather
+// than removing it outright
+func (s *Server) handleDeleteLesson(w http.ResponseWriter, r *http.Request) {
+	path, week, ok := s.authoringTarget(w, r)
+	if !ok {
+		return
+	}
+
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		http.Error(w, "If-Match header required", http.StatusPreconditionRequired)
+		return
+	}
+
+	s.authoringMutex.Lock()
+	defer s.authoringMutex.Unlock()
+
+	current, ok := s.checkLessonPrecondition(w, path, week, ifMatch)
+	if !ok {
+		return
+	}
+
+	trashDir := filepath.Join(s.dataDir, "deleted-lessons")
+	if err := os.MkdirAll(trashDir, 0755); err != nil {
+		slog.Error("failed to create deleted-lessons directory", "dir", trashDir, "error", err)
+		http.Error(w, "Failed to delete lesson", http.StatusInternalServerError)
+		return
+	}
+	trashPath := filepath.Join(trashDir, time.Now().UTC().Format("20060102T150405")+"-"+filepath.Base(path))
+	if err := writeFileAtomic(trashPath, current, 0644); err != nil {
+		slog.Error("failed to save deleted lesson", "file", trashPath, "error", err)
+		http.Error(w, "Failed to delete lesson", http.StatusInternalServerError)
+		return
+	}
+	if err := os.Remove(path); err != nil {
+		slog.Error("failed to remove lesson", "file", path, "error", err)
+		http.Error(w, "Failed to delete lesson", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("lesson deleted", "week", week, "file", path, "saved_to", trashPath)
+	if err := s.scanLessons(); err != nil {
+		slog.Error("failed to rescan lessons after delete", "week", week, "error", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
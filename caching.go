@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// cachePolicy decides the caching headers for an API GET response
+type cachePolicy int
+
+const (
+	cachePrivate cachePolicy = iota // ETag, revalidated, never shared
+	cacheContent                    // Built from the lesson index: ETag and Last-Modified
+	cacheFeed                       // Polled feeds: Last-Modified plus a short freshness window
+	cacheNoStore                    // Session state, never cached
+	cacheBypass                     // Streams and downloads that handle caching themselves
+)
+
+// cachePolicies maps route templates to policies; unlisted GET routes are
+// cachePrivate
+var cachePolicies = map[string]cachePolicy{
+	"/api/course":                                        cacheContent,
+	"/api/lessons":                                       cacheContent,
+	"/api/lessons/{week:[0-9]+}":                         cacheContent,
+	"/api/syllabus":                                      cacheContent,
+	"/api/sections":                                      cacheContent,
+	"/api/sections/{section}":                            cacheContent,
+	"/api/sections/{section}/syllabus":                   cacheContent,
+	"/api/sections/{section}/week/{week:[0-9]+}":         cacheContent,
+	"/api/sections/{section}/week/{week:[0-9]+}/toc":     cacheContent,
+	"/api/sections/{section}/week/{week:[0-9]+}/content": cacheContent,
+	"/api/sections/{section}/week/{week:[0-9]+}/quiz":    cacheContent,
+	"/api/assignments":                                   cacheContent,
+	"/api/sections/{section}/assignments":                cacheContent,
+	"/api/calendar.ics":                                  cacheFeed,
+	"/api/sections/{section}/calendar.ics":               cacheFeed,
+	"/api/feed.atom":                                     cacheFeed,
+	"/api/sections/{section}/feed.atom":                  cacheFeed,
+	"/api/auth/me":                                       cacheNoStore,
+	"/api/events":                                        cacheBypass,
+	"/api/submissions/{id}/download":                     cacheBypass,
+}
+
+// perUserContent lists content routes whose response changes for a signed-in
+// student (e.g. /api/sections merges in progress)
+var perUserContent = map[string]bool{
+	"/api/sections": true,
+}
+
+func contentETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches compares an If-Match or If-None-Match header against etag
+// using weak comparison
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified applies If-None-Match, falling back to If-Modified-Since only
+// when the client sent no ETag
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		return etagMatches(header, etag)
+	}
+	if header := r.Header.Get("If-Modified-Since"); header != "" && !modified.IsZero() {
+		since, err := http.ParseTime(header)
+		return err == nil && !modified.Truncate(time.Second).After(since)
+	}
+	return false
+}
+
+func (s *Server) cacheControl(policy cachePolicy) string {
+	scope := "public"
+	if !s.publicContent {
+		scope = "private"
+	}
+	switch policy {
+	case cacheContent:
+		return scope + ", no-cache"
+	case cacheFeed:
+		return scope + ", max-age=300"
+	case cacheNoStore:
+		return "no-store"
+	}
+	return "private, no-cache"
+}
+
+// lastIndexModified is when the lesson index or course info last changed
+func (s *Server) lastIndexModified() time.Time {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.indexModified
+}
+
+// bufferedResponse holds a handler's body so it can be hashed before
+// anything is sent
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	return b.body.Write(p)
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	b.status = status
+}
+
+// conditionalGET adds ETag, Last-Modified and Cache-Control to API GET and
+// HEAD responses and answers 304 when the client's copy is current. Handlers
+// that set their own ETag (lesson files) keep it.
+func (s *Server) conditionalGET(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		template := ""
+		if route := mux.CurrentRoute(r); route != nil {
+			template, _ = route.GetPathTemplate()
+		}
+		policy, listed := cachePolicies[template]
+		if !listed {
+			policy = cachePrivate
+		}
+		if policy == cacheContent && perUserContent[template] {
+			w.Header().Add("Vary", "Cookie")
+			if requestUser(r) != nil {
+				policy = cachePrivate
+			}
+		}
+
+		switch policy {
+		case cacheBypass:
+			next.ServeHTTP(w, r)
+			return
+		case cacheNoStore:
+			w.Header().Set("Cache-Control", s.cacheControl(policy))
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		buffered := &bufferedResponse{header: w.Header(), status: http.StatusOK}
+		next.ServeHTTP(buffered, r)
+
+		if buffered.status != http.StatusOK {
+			w.WriteHeader(buffered.status)
+			w.Write(buffered.body.Bytes())
+			return
+		}
+
+		header := w.Header()
+		if header.Get("ETag") == "" {
+			header.Set("ETag", contentETag(buffered.body.Bytes()))
+		}
+		var modified time.Time
+		if policy == cacheContent || policy == cacheFeed {
+			modified = s.lastIndexModified()
+			header.Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+		}
+		if header.Get("Cache-Control") == "" {
+			header.Set("Cache-Control", s.cacheControl(policy))
+		}
+
+		if notModified(r, header.Get("ETag"), modified) {
+			header.Del("Content-Type")
+			header.Del("Content-Length")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		header.Set("Content-Length", strconv.Itoa(buffered.body.Len()))
+		w.WriteHeader(http.StatusOK)
+		if r.Method != http.MethodHead {
+			w.Write(buffered.body.Bytes())
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+const cachingLesson = "---\ntitle: Selectors\nweek: 1\n---\n\nBody\n"
+
+func TestConditionalGET(t *testing.T) {
+	ts := newTestServer(t, map[string]string{"section1-html-css/week1.md": cachingLesson})
+
+	rec := ts.do(nil, http.MethodGet, "/api/lessons", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d", rec.Code)
+	}
+	etag := rec.Header().Get("ETag")
+	modified := rec.Header().Get("Last-Modified")
+	if etag == "" || modified == "" {
+		t.Fatalf("missing validators: ETag %q, Last-Modified %q", etag, modified)
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "public, no-cache" {
+		t.Errorf("Cache-Control = %q, want public, no-cache", got)
+	}
+	body := rec.Body.String()
+
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		status  int
+	}{
+		{"matching etag", http.MethodGet, map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{"etag in a list", http.MethodGet, map[string]string{"If-None-Match": `"other", ` + etag}, http.StatusNotModified},
+		{"weak etag", http.MethodGet, map[string]string{"If-None-Match": "W/" + etag}, http.StatusNotModified},
+		{"stale etag", http.MethodGet, map[string]string{"If-None-Match": `"stale"`}, http.StatusOK},
+		{"not modified since", http.MethodGet, map[string]string{"If-Modified-Since": modified}, http.StatusNotModified},
+		{"modified since", http.MethodGet, map[string]string{
+			"If-Modified-Since": time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)}, http.StatusOK},
+		{"etag wins over date", http.MethodGet, map[string]string{
+			"If-None-Match": `"stale"`, "If-Modified-Since": modified}, http.StatusOK},
+		{"head", http.MethodHead, nil, http.StatusOK},
+		{"head matching etag", http.MethodHead, map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ts.do(nil, tt.method, "/api/lessons", nil, tt.headers)
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Header().Get("ETag"); got != etag {
+				t.Errorf("ETag = %q, want %q", got, etag)
+			}
+			wantBody := body
+			if tt.method == http.MethodHead || tt.status == http.StatusNotModified {
+				wantBody = ""
+			}
+			if got := rec.Body.String(); got != wantBody {
+				t.Errorf("body = %q, want %q", got, wantBody)
+			}
+		})
+	}
+}
+
+func TestConditionalGETPerUser(t *testing.T) {
+	ts := newTestServer(t, map[string]string{"section1-html-css/week1.md": cachingLesson})
+	client := ts.login("student1")
+
+	me := ts.do(client, http.MethodGet, "/api/auth/me", nil, nil)
+	if got := me.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("/api/auth/me Cache-Control = %q, want no-store", got)
+	}
+
+	rec := ts.do(client, http.MethodGet, "/api/progress", nil, nil)
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("private response has no ETag")
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "private, no-cache" {
+		t.Errorf("Cache-Control = %q, want private, no-cache", got)
+	}
+	if rec := ts.do(client, http.MethodGet, "/api/progress", nil, map[string]string{"If-None-Match": etag}); rec.Code != http.StatusNotModified {
+		t.Errorf("status %d, want %d", rec.Code, http.StatusNotModified)
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const icsDateFormat = "20060102"
+
+var defaultClassDays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+
+// classDays converts course.yaml class_days into weekdays, falling back to Monday-Friday
+func classDays(names []string) []time.Weekday {
+	var days []time.Weekday
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			full := strings.ToLower(d.String())
+			if name == full || (len(name) >= 3 && strings.HasPrefix(full, name)) {
+				days = append(days, d)
+				break
+			}
+		}
+	}
+	if len(days) == 0 {
+		return defaultClassDays
+	}
+	return days
+}
+
+// weekStartDate returns the first day of a global course week
+func weekStartDate(start time.Time, week int) time.Time {
+	return start.AddDate(0, 0, (week-1)*7)
+}
+
+// dayDate returns the date "Day N" of a week falls on, walking class days
+// from the week start. Days beyond the class days wrap into later weekdays.
+func dayDate(start time.Time, week, day int, days []time.Weekday) time.Time {
+	weekStart := weekStartDate(start, week)
+	if day >= 1 && day <= len(days) {
+		offset := (int(days[day-1]) - int(weekStart.Weekday()) + 7) % 7
+		return weekStart.AddDate(0, 0, offset)
+	}
+	return weekStart.AddDate(0, 0, day-1)
+}
+
+// parseCourseDate accepts the date formats used in course.yaml and lesson frontmatter
+func parseCourseDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{"2006-01-02", "2006-01-02T15:04", "2006-01-02T15:04:05", time.RFC3339} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// icsEscape escapes TEXT values per RFC 5545 section 3.3.11
+func icsEscape(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, ";", `\;`)
+	value = strings.ReplaceAll(value, ",", `\,`)
+	value = strings.ReplaceAll(value, "\r\n", `\n`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	return value
+}
+
+// icsWriteLine writes a content line folded at 75 octets with CRLF endings
+func icsWriteLine(b *strings.Builder, line string) {
+	for len(line) > 75 {
+		cut := 75
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+func isRuneStart(c byte) bool {
+	return c&0xC0 != 0x80
+}
+
+type calendarEvent struct {
+	UID         string
+	Start       time.Time
+	End         time.Time
+	AllDay      bool
+	Summary     string
+	Description string
+	Stamp       time.Time
+}
+
+// lessonEvents builds one event per "Day N" block, or one per week when
+// the lesson has no Day headings, plus due-date events for the lesson and
+// its assignments.
+func lessonEvents(lesson *Lesson, start time.Time, days []time.Weekday) []calendarEvent {
+	var events []calendarEvent
+	stamp := lesson.CreatedAt.UTC()
+
+	if len(lesson.Days) == 0 {
+		weekStart := weekStartDate(start, lesson.Week)
+		events = append(events, calendarEvent{
+			UID:         fmt.Sprintf("week-%d@course-app", lesson.Week),
+			Start:       weekStart,
+			End:         weekStart.AddDate(0, 0, 7),
+			AllDay:      true,
+			Summary:     fmt.Sprintf("Week %d: %s", lesson.Week, lesson.Title),
+			Description: lesson.Description,
+			Stamp:       stamp,
+		})
+	}
+
+	for _, day := range lesson.Days {
+		date := dayDate(start, lesson.Week, day.Number, days)
+		summary := fmt.Sprintf("Week %d Day %d", lesson.Week, day.Number)
+		if day.Title != "" {
+			summary += ": " + day.Title
+		}
+		events = append(events, calendarEvent{
+			UID:         fmt.Sprintf("week-%d-day-%d@course-app", lesson.Week, day.Number),
+			Start:       date,
+			End:         date.AddDate(0, 0, 1),
+			AllDay:      true,
+			Summary:     summary,
+			Description: lesson.Title + "\n" + lesson.Description,
+			Stamp:       stamp,
+		})
+	}
+
+	if due, ok := parseCourseDate(lesson.Due); ok {
+		event := calendarEvent{
+			UID:         fmt.Sprintf("week-%d-due@course-app", lesson.Week),
+			Start:       due,
+			End:         due.AddDate(0, 0, 1),
+			AllDay:      true,
+			Summary:     fmt.Sprintf("Due: Week %d - %s", lesson.Week, lesson.Title),
+			Description: lesson.Description,
+			Stamp:       stamp,
+		}
+		if strings.Contains(lesson.Due, "T") {
+			event.AllDay = false
+			event.End = due
+		}
+		events = append(events, event)
+	}
+
+	for _, a := range lesson.Assignments {
+		if a.DueAt == nil {
+			continue
+		}
+		summary := "Due: " + a.Title
+		if a.Points > 0 {
+			summary += fmt.Sprintf(" (%g pts)", a.Points)
+		}
+		events = append(events, calendarEvent{
+			UID:         fmt.Sprintf("assignment-%s@course-app", a.ID),
+			Start:       *a.DueAt,
+			End:         *a.DueAt,
+			Summary:     summary,
+			Description: a.Description,
+			Stamp:       stamp,
+		})
+	}
+
+	return events
+}
+
+func renderCalendar(name string, events []calendarEvent) string {
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Start.Before(events[j].Start)
+	})
+
+	var b strings.Builder
+	icsWriteLine(&b, "BEGIN:VCALENDAR")
+	icsWriteLine(&b, "VERSION:2.0")
+	icsWriteLine(&b, "PRODID:-//course-app//Course Schedule//EN")
+	icsWriteLine(&b, "CALSCALE:GREGORIAN")
+	icsWriteLine(&b, "X-WR-CALNAME:"+icsEscape(name))
+
+	for _, event := range events {
+		icsWriteLine(&b, "BEGIN:VEVENT")
+		icsWriteLine(&b, "UID:"+event.UID)
+		icsWriteLine(&b, "DTSTAMP:"+event.Stamp.Format("20060102T150405Z"))
+		if event.AllDay {
+			icsWriteLine(&b, "DTSTART;VALUE=DATE:"+event.Start.Format(icsDateFormat))
+			icsWriteLine(&b, "DTEND;VALUE=DATE:"+event.End.Format(icsDateFormat))
+		} else {
+			icsWriteLine(&b, "DTSTART:"+event.Start.UTC().Format("20060102T150405Z"))
+			icsWriteLine(&b, "DTEND:"+event.End.UTC().Format("20060102T150405Z"))
+		}
+		icsWriteLine(&b, "SUMMARY:"+icsEscape(event.Summary))
+		if event.Description != "" {
+			icsWriteLine(&b, "DESCRIPTION:"+icsEscape(event.Description))
+		}
+		icsWriteLine(&b, "END:VEVENT")
+	}
+
+	icsWriteLine(&b, "END:VCALENDAR")
+	return b.String()
+}
+
+// buildCalendar renders the schedule for the given lessons, or reports
+// false when course.yaml has no usable start_date.
+func (s *Server) buildCalendar(name string, lessons []*Lesson) (string, bool) {
+	s.mutex.RLock()
+	startDate := s.course.StartDate
+	days := classDays(s.course.ClassDays)
+	s.mutex.RUnlock()
+
+	start, ok := parseCourseDate(startDate)
+	if !ok {
+		return "", false
+	}
+
+	var events []calendarEvent
+	for _, lesson := range lessons {
+		events = append(events, lessonEvents(lesson, start, days)...)
+	}
+	return renderCalendar(name, events), true
+}
+
+func writeCalendar(w http.ResponseWriter, calendar string) {
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Write([]byte(calendar))
+}
+
+func (s *Server) handleCalendar(w http.ResponseWriter, r *http.Request) {
+	s.mutex.RLock()
+	name := s.course.Title
+	var lessons []*Lesson
+	for _, lesson := range s.lessons {
+		lessons = append(lessons, lesson)
+	}
+	s.mutex.RUnlock()
+
+	calendar, ok := s.buildCalendar(name, lessons)
+	if !ok {
+		http.Error(w, "Course start_date not configured in course.yaml", http.StatusNotFound)
+		return
+	}
+	writeCalendar(w, calendar)
+}
+
+func (s *Server) handleSectionCalendar(w http.ResponseWriter, r *http.Request) {
+	sectionID := mux.Vars(r)["section"]
+
+	s.mutex.RLock()
+	section, exists := s.sections[sectionID]
+	var name string
+	var lessons []*Lesson
+	if exists {
+		name = s.course.Title + " - " + section.Name
+		lessons = append(lessons, section.Lessons...)
+	}
+	s.mutex.RUnlock()
+
+	if !exists {
+		http.Error(w, "Section not found", http.StatusNotFound)
+		return
+	}
+
+	calendar, ok := s.buildCalendar(name, lessons)
+	if !ok {
+		http.Error(w, "Course start_date not configured in course.yaml", http.StatusNotFound)
+		return
+	}
+	writeCalendar(w, calendar)
+}
@@ -0,0 +1,559 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// version is set at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
+const usage = `Usage: course-app <command> [flags]
+
+Commands:
+  serve     Run the web server (default)
+  scan      Scan the lessons directory and list what was found
+  lint      Check every lesson file for problems
+  export    Export the API as static JSON files
+  new       Create a lesson file from a template
+  version   Print version information
+
+Run "course-app <command> -h" for the flags of a command.
+
+The older positional form still works:
+  course-app [lessons-dir] [port]
+
+Settings are read, in increasing precedence, from a YAML config file
+(-config or COURSE_CONFIG), environment variables and flags:
+  COURSE_LESSONS_DIR     lessons directory (default ./lessons)
+  COURSE_LISTEN          listen address (default :8080)
+  COURSE_DATA_DIR        local data directory (default ./data)
+  COURSE_CORS_ORIGINS    comma-separated allowed origins (default *);
+                         an explicit list also allows the session cookie,
+                         from other sites only when serving HTTPS
+  COURSE_LOG_LEVEL       debug, info, warn or error
+  COURSE_LOG_FORMAT      text or json
+  COURSE_FRONTEND        built front end directory (default: embedded)
+  COURSE_PUBLIC_CONTENT  false requires sign-in for lesson content
+  COURSE_CSP             Content-Security-Policy for front end pages
+  COURSE_TLS             true serves HTTPS
+  COURSE_TLS_CERT        certificate file; with COURSE_TLS_KEY, replaces
+  COURSE_TLS_KEY         the generated local CA (data dir tls/)
+  COURSE_TLS_HOSTS       extra comma-separated names or IPs for the
+                         generated certificate
+  COURSE_HTTP_REDIRECT   plain HTTP listen address that redirects to HTTPS
+`
+
+// config holds the settings shared by the commands. Field names double as
+// the keys of the config file.
+type config struct {
+	LessonsDir    string   `yaml:"lessons_dir"`
+	Listen        string   `yaml:"listen"`
+	DataDir       string   `yaml:"data_dir"`
+	CORSOrigins   []string `yaml:"cors_origins"`
+	LogLevel      string   `yaml:"log_level"` // Empty uses the command's default
+	LogFormat     string   `yaml:"log_format"`
+	Frontend      string   `yaml:"frontend"`
+	PublicContent bool     `yaml:"public_content"`
+	// Content-Security-Policy for front end pages; empty uses the default
+	ContentSecurityPolicy string `yaml:"content_security_policy"`
+	TLS                   bool   `yaml:"tls"`
+	// Without cert and key, a local CA and server certificate are generated
+	// under the data directory
+	TLSCert  string   `yaml:"tls_cert"`
+	TLSKey   string   `yaml:"tls_key"`
+	TLSHosts []string `yaml:"tls_hosts"` // Extra names for the generated certificate
+	// Plain HTTP listen address that redirects to the TLS listener
+	HTTPRedirect string `yaml:"http_redirect"`
+}
+
+func defaultConfig() config {
+	return config{
+		LessonsDir:    "./lessons",
+		Listen:        ":8080",
+		DataDir:       "./data",
+		CORSOrigins:   []string{"*"},
+		LogFormat:     "text",
+		PublicContent: true,
+	}
+}
+
+func (c *config) readFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *config) readEnv() error {
+	for name, target := range map[string]*string{
+		"COURSE_LESSONS_DIR":   &c.LessonsDir,
+		"COURSE_LISTEN":        &c.Listen,
+		"COURSE_DATA_DIR":      &c.DataDir,
+		"COURSE_LOG_LEVEL":     &c.LogLevel,
+		"COURSE_LOG_FORMAT":    &c.LogFormat,
+		"COURSE_FRONTEND":      &c.Frontend,
+		"COURSE_CSP":           &c.ContentSecurityPolicy,
+		"COURSE_TLS_CERT":      &c.TLSCert,
+		"COURSE_TLS_KEY":       &c.TLSKey,
+		"COURSE_HTTP_REDIRECT": &c.HTTPRedirect,
+	} {
+		if value := os.Getenv(name); value != "" {
+			*target = value
+		}
+	}
+	if value := os.Getenv("COURSE_CORS_ORIGINS"); value != "" {
+		c.CORSOrigins = splitList(value)
+	}
+	if value := os.Getenv("COURSE_PUBLIC_CONTENT"); value != "" {
+		public, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid COURSE_PUBLIC_CONTENT %q", value)
+		}
+		c.PublicContent = public
+	}
+	if value := os.Getenv("COURSE_TLS"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid COURSE_TLS %q", value)
+		}
+		c.TLS = enabled
+	}
+	if value := os.Getenv("COURSE_TLS_HOSTS"); value != "" {
+		c.TLSHosts = splitList(value)
+	}
+	return nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// listenAddress accepts a bare port as well as host:port
+func listenAddress(listen string) string {
+	if !strings.Contains(listen, ":") {
+		return ":" + listen
+	}
+	return listen
+}
+
+// configPath finds -config in args before the flag set exists, so the file
+// can supply the flag defaults
+func configPath(args []string) string {
+	for i, arg := range args {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "config" {
+			continue
+		}
+		if hasValue {
+			return value
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return os.Getenv("COURSE_CONFIG")
+}
+
+// commandFlags loads the config file and environment, then returns a flag
+// set whose defaults are those values. serve adds the server-only flags.
+func commandFlags(name, positional string, args []string, serve bool) (*config, *flag.FlagSet, error) {
+	cfg := defaultConfig()
+	path := configPath(args)
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := cfg.readEnv(); err != nil {
+		return nil, nil, err
+	}
+
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: course-app %s [flags] %s\n\nFlags:\n", name, positional)
+		flags.PrintDefaults()
+	}
+	flags.String("config", path, "YAML config file (env COURSE_CONFIG)")
+	flags.StringVar(&cfg.LessonsDir, "lessons", cfg.LessonsDir, "lessons directory")
+	flags.StringVar(&cfg.LogLevel, "log-level", cfg.LogLevel, "log level: debug, info, warn or error")
+	flags.StringVar(&cfg.LogFormat, "log-format", cfg.LogFormat, "log format: text or json")
+	if serve {
+		flags.StringVar(&cfg.Listen, "listen", cfg.Listen, "listen address, host:port or port")
+		flags.StringVar(&cfg.DataDir, "data", cfg.DataDir, "local data directory")
+		flags.StringVar(&cfg.Frontend, "frontend", cfg.Frontend, "built front end directory (default: embedded)")
+		flags.BoolVar(&cfg.PublicContent, "public-content", cfg.PublicContent, "serve lesson content without sign-in")
+		flags.StringVar(&cfg.ContentSecurityPolicy, "csp", cfg.ContentSecurityPolicy, "Content-Security-Policy for front end pages (default tuned for the Astro build)")
+		flags.Func("cors-origins", "comma-separated allowed origins; an explicit list allows credentials (default "+strings.Join(cfg.CORSOrigins, ",")+")", func(value string) error {
+			cfg.CORSOrigins = splitList(value)
+			return nil
+		})
+		flags.BoolVar(&cfg.TLS, "tls", cfg.TLS, "serve HTTPS")
+		flags.StringVar(&cfg.TLSCert, "tls-cert", cfg.TLSCert, "TLS certificate file (default: generated from a local CA)")
+		flags.StringVar(&cfg.TLSKey, "tls-key", cfg.TLSKey, "TLS private key file")
+		flags.Func("tls-hosts", "comma-separated extra names or IPs for the generated certificate", func(value string) error {
+			cfg.TLSHosts = splitList(value)
+			return nil
+		})
+		flags.StringVar(&cfg.HTTPRedirect, "http-redirect", cfg.HTTPRedirect, "plain HTTP listen address that redirects to HTTPS, host:port or port")
+	}
+	return &cfg, flags, nil
+}
+
+// setupLogging applies the configured log level, or fallback when none was
+// given
+func (c *config) setupLogging(fallback string) error {
+	level := c.LogLevel
+	if level == "" {
+		level = fallback
+	}
+	return setupLogging(level, c.LogFormat)
+}
+
+// runCLI dispatches to a command, treating anything that is not a command
+// name as arguments to serve
+func runCLI(args []string) error {
+	command := "serve"
+	if len(args) > 0 {
+		switch args[0] {
+		case "serve", "scan", "lint", "export", "new", "version":
+			command, args = args[0], args[1:]
+		case "help", "-h", "-help", "--help":
+			fmt.Print(usage)
+			return nil
+		}
+	}
+
+	var err error
+	switch command {
+	case "serve":
+		err = runServe(args)
+	case "scan":
+		err = runScan(args)
+	case "lint":
+		err = runLint(args)
+	case "export":
+		err = runExportCommand(args)
+	case "new":
+		err = runNew(args)
+	case "version":
+		fmt.Println(versionString())
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	return err
+}
+
+func versionString() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "course-app " + version
+	}
+
+	var revision, modified string
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			if setting.Value == "true" {
+				modified = "-dirty"
+			}
+		}
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if revision == "" {
+		return fmt.Sprintf("course-app %s (%s)", version, info.GoVersion)
+	}
+	return fmt.Sprintf("course-app %s (%s%s, %s)", version, revision, modified, info.GoVersion)
+}
+
+func runServe(args []string) error {
+	cfg, flags, err := commandFlags("serve", "[lessons-dir] [port]", args, true)
+	if err != nil {
+		return err
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	// Positional arguments from older startup scripts
+	if flags.NArg() > 0 {
+		cfg.LessonsDir = flags.Arg(0)
+	}
+	if flags.NArg() > 1 {
+		cfg.Listen = flags.Arg(1)
+	}
+	if flags.NArg() > 2 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(flags.Args()[2:], " "))
+	}
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		return errors.New("-tls-cert and -tls-key must be given together")
+	}
+	if cfg.HTTPRedirect != "" && !cfg.TLS {
+		return errors.New("-http-redirect requires -tls")
+	}
+	if err := cfg.setupLogging("info"); err != nil {
+		return err
+	}
+	return serveCourse(*cfg)
+}
+
+// loadIndex builds a server and scans the lessons without opening stores or
+// watching files, for the offline commands. Unlike NewServer it never
+// creates a missing lessons directory.
+func loadIndex(lessonsDir string) (*Server, error) {
+	if _, err := os.Stat(lessonsDir); err != nil {
+		return nil, fmt.Errorf("lessons directory: %w", err)
+	}
+
+	server := newIndexServer(lessonsDir)
+	if err := server.loadCourseInfo(); err != nil {
+		server.close()
+		return nil, err
+	}
+	if err := server.scanLessons(); err != nil {
+		server.close()
+		return nil, fmt.Errorf("failed to scan lessons: %w", err)
+	}
+	return server, nil
+}
+
+func sortedSections(s *Server) []*Section {
+	sections := make([]*Section, 0, len(s.sections))
+	for _, section := range s.sections {
+		sections = append(sections, section)
+	}
+	sort.Slice(sections, func(i, j int) bool {
+		return sections[i].WeekStart < sections[j].WeekStart
+	})
+	return sections
+}
+
+// runScan prints the lesson index and fails when any file did not parse
+func runScan(args []string) error {
+	cfg, flags, err := commandFlags("scan", "", args, false)
+	if err != nil {
+		return err
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := cfg.setupLogging("warn"); err != nil {
+		return err
+	}
+
+	server, err := loadIndex(cfg.LessonsDir)
+	if err != nil {
+		return err
+	}
+	defer server.close()
+
+	fmt.Printf("%s\n", server.course.Title)
+	for _, section := range sortedSections(server) {
+		fmt.Printf("\n%s (weeks %d-%d): %d lessons\n", section.ID, section.WeekStart, section.WeekEnd, len(section.Lessons))
+		for _, lesson := range section.Lessons {
+			fmt.Printf("  week %-3d %-50s %s\n", lesson.Week-section.WeekStart+1, lesson.Title, server.relativeLessonPath(lesson.FilePath))
+		}
+	}
+	fmt.Printf("\n%d lessons in %d sections\n", len(server.lessons), len(server.sections))
+
+	if failures := server.metrics.parseFailures.Load(); failures > 0 {
+		return fmt.Errorf("%d lesson files failed to parse", failures)
+	}
+	return nil
+}
+
+// runLint checks every lesson file in the section directories with the same
+// rules as the preview API
+func runLint(args []string) error {
+	cfg, flags, err := commandFlags("lint", "", args, false)
+	if err != nil {
+		return err
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	// Parse warnings are reported below, not logged
+	if err := cfg.setupLogging("error"); err != nil {
+		return err
+	}
+
+	server, err := loadIndex(cfg.LessonsDir)
+	if err != nil {
+		return err
+	}
+	defer server.close()
+
+	var files, problems int
+	for _, section := range sortedSections(server) {
+		sectionPath := filepath.Join(server.lessonsDir, section.ID)
+		err := filepath.WalkDir(sectionPath, func(path string, d fs.DirEntry, err error) error {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			if err != nil || d.IsDir() || !strings.HasSuffix(strings.ToLower(d.Name()), ".md") {
+				return err
+			}
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			files++
+
+			lesson, warnings, err := server.parseLessonContent(path, content, time.Now(), section.ID, section.Name, section.WeekStart)
+			if err != nil {
+				fmt.Printf("%s: %v\n", server.relativeLessonPath(path), err)
+				problems++
+				return nil
+			}
+			warnings = append(warnings, server.lintLesson(lesson, content, section)...)
+			for _, warning := range warnings {
+				fmt.Printf("%s: %s\n", server.relativeLessonPath(path), warning)
+			}
+			problems += len(warnings)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to lint %s: %w", section.ID, err)
+		}
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("%d problems in %d lesson files", problems, files)
+	}
+	fmt.Printf("%d lesson files, no problems\n", files)
+	return nil
+}
+
+// runExportCommand implements `course-app export api [flags]`. The target
+// may come before or after the flags.
+func runExportCommand(args []string) error {
+	cfg, flags, err := commandFlags("export", "api", args, false)
+	if err != nil {
+		return err
+	}
+	outDir := flags.String("out", "./api-export", "directory to write the exported API files to")
+
+	var target string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		target, args = args[0], args[1:]
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if target == "" && flags.NArg() > 0 {
+		target = flags.Arg(0)
+	} else if flags.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	}
+	if target != "api" {
+		flags.Usage()
+		return fmt.Errorf("unknown export target %q; only api is supported", target)
+	}
+	// Every exported endpoint passes through the access log
+	if err := cfg.setupLogging("warn"); err != nil {
+		return err
+	}
+
+	return runExport(cfg.LessonsDir, *outDir)
+}
+
+// runNew writes a lesson skeleton at the path the authoring API would use
+func runNew(args []string) error {
+	cfg, flags, err := commandFlags("new", "", args, false)
+	if err != nil {
+		return err
+	}
+	sectionID := flags.String("section", "", "section id, e.g. section1-html-css (required)")
+	week := flags.Int("week", 0, "week within the section, starting at 1 (required)")
+	title := flags.String("title", "", "lesson title (required)")
+	description := flags.String("description", "", "lesson description")
+	due := flags.String("due", "", "due date, YYYY-MM-DD")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *sectionID == "" || *week < 1 || strings.TrimSpace(*title) == "" {
+		flags.Usage()
+		return fmt.Errorf("-section, -week and -title are required")
+	}
+	if err := cfg.setupLogging("warn"); err != nil {
+		return err
+	}
+
+	server, err := loadIndex(cfg.LessonsDir)
+	if err != nil {
+		return err
+	}
+	defer server.close()
+
+	section, exists := server.sections[*sectionID]
+	if !exists {
+		var ids []string
+		for _, section := range sortedSections(server) {
+			ids = append(ids, section.ID)
+		}
+		return fmt.Errorf("unknown section %q (sections: %s)", *sectionID, strings.Join(ids, ", "))
+	}
+	globalWeek := section.WeekStart + *week - 1
+	if globalWeek > section.WeekEnd {
+		return fmt.Errorf("week %d is outside %s (%d weeks)", *week, section.ID, section.WeekEnd-section.WeekStart+1)
+	}
+	if existing, exists := server.lessons[globalWeek]; exists {
+		return fmt.Errorf("week %d already has a lesson: %s", *week, existing.FilePath)
+	}
+
+	path := filepath.Join(server.lessonsDir, section.ID, fmt.Sprintf("week%d.md", globalWeek))
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+
+	content := "## Overview\n\n## Activities\n"
+	data, err := buildLessonFile(lessonWriteRequest{
+		Title:       *title,
+		Description: description,
+		Due:         due,
+		Content:     &content,
+	}, globalWeek)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create section directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write lesson: %w", err)
+	}
+
+	fmt.Println(path)
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExportCommandFlags(t *testing.T) {
+	lessonsDir := filepath.Join(t.TempDir(), "lessons")
+	if err := os.MkdirAll(filepath.Join(lessonsDir, "section1-html-css"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	lesson := "---\ntitle: Selectors\nweek: 1\n---\n\nBody\n"
+	if err := os.WriteFile(filepath.Join(lessonsDir, "section1-html-css", "week1.md"), []byte(lesson), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runCLI([]string{"export", "-h"}); err != nil {
+		t.Errorf("export -h: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		args func(out string) []string
+	}{
+		{"target first", func(out string) []string {
+			return []string{"export", "api", "--lessons", lessonsDir, "--out", out, "--log-level", "warn", "--log-format", "json"}
+		}},
+		{"target last", func(out string) []string {
+			return []string{"export", "-lessons", lessonsDir, "-out", out, "api"}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "export")
+			if err := runCLI(tt.args(out)); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(filepath.Join(out, "manifest.json")); err != nil {
+				t.Errorf("manifest missing: %v", err)
+			}
+		})
+	}
+
+	failures := [][]string{
+		{"export"},
+		{"export", "site"},
+		{"export", "api", "--lessons", filepath.Join(lessonsDir, "missing")},
+		{"export", "api", "--no-such-flag"},
+	}
+	for _, args := range failures {
+		if err := runCLI(args); err == nil {
+			t.Errorf("%v succeeded, want an error", args)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	// Responses smaller than this are not worth compressing
+	minCompressSize = 1024
+	apiBrotliLevel  = 5
+)
+
+// compressibleTypes are the media types worth compressing
+var compressibleTypes = []string{
+	"text/",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"application/atom+xml",
+	"image/svg+xml",
+}
+
+func compressibleType(contentType string) bool {
+	for _, prefix := range compressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptedEncodings parses Accept-Encoding into content codings and their
+// q-values
+func acceptedEncodings(acceptEncoding string) map[string]float64 {
+	accepted := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		quality := 1.0
+		for _, param := range fields[1:] {
+			if value, found := strings.CutPrefix(strings.TrimSpace(param), "q="); found {
+				if q, err := strconv.ParseFloat(value, 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if name != "" {
+			accepted[name] = quality
+		}
+	}
+	return accepted
+}
+
+// negotiateEncoding picks the best of the available encodings, in order of
+// preference, that the client accepts by name or through "*", honouring
+// q=0. It returns "" for identity.
+func negotiateEncoding(acceptEncoding string, available ...string) string {
+	accepted := acceptedEncodings(acceptEncoding)
+
+	best, bestQuality := "", 0.0
+	for _, encoding := range available {
+		quality, ok := accepted[encoding]
+		if !ok {
+			quality, ok = accepted["*"]
+		}
+		if ok && quality > bestQuality {
+			best, bestQuality = encoding, quality
+		}
+	}
+	return best
+}
+
+var (
+	gzipWriters = sync.Pool{New: func() interface{} {
+		return gzip.NewWriter(io.Discard)
+	}}
+	brotliWriters = sync.Pool{New: func() interface{} {
+		return brotli.NewWriterLevel(io.Discard, apiBrotliLevel)
+	}}
+)
+
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// compressWriter decides on the first write whether the response is worth
+// compressing, based on status, Content-Type and Content-Length
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	encoder     encoder
+	decided     bool
+	wroteHeader bool
+}
+
+// weakenETag marks the ETag weak, since compressed bytes differ from the
+// identity response a strong validator describes
+func weakenETag(header http.Header) {
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		header.Set("ETag", "W/"+etag)
+	}
+}
+
+func (c *compressWriter) decide(status int) {
+	if c.decided {
+		return
+	}
+	c.decided = true
+
+	header := c.Header()
+	if status == http.StatusNotModified {
+		// Match the validator the full response would have carried
+		weakenETag(header)
+		return
+	}
+	if status < 200 || status == http.StatusNoContent ||
+		header.Get("Content-Encoding") != "" || !compressibleType(header.Get("Content-Type")) {
+		return
+	}
+	if length, err := strconv.Atoi(header.Get("Content-Length")); err == nil && length < minCompressSize {
+		return
+	}
+
+	header.Set("Content-Encoding", c.encoding)
+	header.Del("Content-Length")
+	weakenETag(header)
+
+	switch c.encoding {
+	case "br":
+		w := brotliWriters.Get().(*brotli.Writer)
+		w.Reset(c.ResponseWriter)
+		c.encoder = w
+	case "gzip":
+		w := gzipWriters.Get().(*gzip.Writer)
+		w.Reset(c.ResponseWriter)
+		c.encoder = w
+	}
+}
+
+func (c *compressWriter) WriteHeader(status int) {
+	if c.wroteHeader {
+		return
+	}
+	c.wroteHeader = true
+	c.decide(status)
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *compressWriter) Write(p []byte) (int, error) {
+	if !c.wroteHeader {
+		if c.Header().Get("Content-Type") == "" {
+			c.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.encoder != nil {
+		return c.encoder.Write(p)
+	}
+	return c.ResponseWriter.Write(p)
+}
+
+// Flush keeps streaming responses streaming
+func (c *compressWriter) Flush() {
+	if c.encoder != nil {
+		c.encoder.Flush()
+	}
+	if flusher, ok := c.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying connection
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+func (c *compressWriter) close() {
+	if c.encoder == nil {
+		return
+	}
+	c.encoder.Close()
+	switch w := c.encoder.(type) {
+	case *brotli.Writer:
+		brotliWriters.Put(w)
+	case *gzip.Writer:
+		gzipWriters.Put(w)
+	}
+}
+
+// compressResponse compresses API responses with br or gzip per
+// Accept-Encoding
+func compressResponse(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), "br", "gzip")
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
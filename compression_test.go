@@ -0,0 +1,138 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		accept    string
+		available []string
+		want      string
+	}{
+		{"", []string{"br", "gzip"}, ""},
+		{"gzip, deflate, br", []string{"br", "gzip"}, "br"},
+		{"gzip", []string{"br", "gzip"}, "gzip"},
+		{"br", []string{"br", "gzip"}, "br"},
+		{"br;q=0.5, gzip", []string{"br", "gzip"}, "gzip"},
+		{"br;q=0, gzip;q=0", []string{"br", "gzip"}, ""},
+		{"*", []string{"br", "gzip"}, "br"},
+		{"br;q=0, *", []string{"br", "gzip"}, "gzip"},
+		{"identity", []string{"br", "gzip"}, ""},
+		{"GZIP", []string{"br", "gzip"}, "gzip"},
+		// Only encodings the client named or covered with * are chosen
+		{"br", []string{"gzip"}, ""},
+		{"gzip", []string{"br"}, ""},
+		{"*;q=0.1", []string{"gzip"}, "gzip"},
+		{"gzip", nil, ""},
+	}
+	for _, tt := range tests {
+		if got := negotiateEncoding(tt.accept, tt.available...); got != tt.want {
+			t.Errorf("negotiateEncoding(%q, %v) = %q, want %q", tt.accept, tt.available, got, tt.want)
+		}
+	}
+}
+
+func TestCompressResponse(t *testing.T) {
+	large := `{"data":"` + strings.Repeat("lesson ", 500) + `"}`
+	handler := compressResponse(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := large
+		if r.URL.Path == "/small" {
+			body = `{"ok":true}`
+		}
+		// Like conditionalGET, which buffers API responses
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Header().Set("ETag", `"abc"`)
+		if r.Header.Get("If-None-Match") != "" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if r.Method != http.MethodHead {
+			io.WriteString(w, body)
+		}
+	}))
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		accept   string
+		encoding string
+	}{
+		{"brotli", http.MethodGet, "/", "gzip, br", "br"},
+		{"gzip", http.MethodGet, "/", "gzip", "gzip"},
+		{"identity", http.MethodGet, "/", "", ""},
+		{"unsupported only", http.MethodGet, "/", "deflate", ""},
+		{"too small", http.MethodGet, "/small", "gzip", ""},
+		{"head", http.MethodHead, "/", "gzip", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Accept-Encoding", tt.accept)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Content-Encoding"); got != tt.encoding {
+				t.Fatalf("Content-Encoding = %q, want %q", got, tt.encoding)
+			}
+			if !strings.Contains(rec.Header().Get("Vary"), "Accept-Encoding") {
+				t.Error("missing Vary: Accept-Encoding")
+			}
+
+			var body io.Reader = rec.Body
+			switch tt.encoding {
+			case "br":
+				body = brotli.NewReader(rec.Body)
+			case "gzip":
+				zr, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body = zr
+			}
+			if tt.encoding != "" {
+				if got := rec.Header().Get("ETag"); got != `W/"abc"` {
+					t.Errorf("ETag = %q, want it weakened", got)
+				}
+			}
+			if tt.method == http.MethodHead || tt.path == "/small" {
+				return
+			}
+			decoded, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(decoded) != large {
+				t.Errorf("decoded body differs from the original")
+			}
+		})
+	}
+
+	t.Run("not modified", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		req.Header.Set("If-None-Match", `W/"abc"`)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusNotModified {
+			t.Fatalf("status %d, want %d", rec.Code, http.StatusNotModified)
+		}
+		if got := rec.Header().Get("ETag"); got != `W/"abc"` {
+			t.Errorf("ETag = %q, want the weak validator the full response carries", got)
+		}
+		if rec.Body.Len() != 0 {
+			t.Errorf("304 has a body")
+		}
+	})
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	maxBufferedEvents = 256
+	eventHeartbeat    = 25 * time.Second
+	subscriberBuffer  = 32
+)
+
+// Event types sent on /api/events
+const (
+	eventLessonUpdated = "lesson.updated"
+	eventLessonRemoved = "lesson.removed"
+	eventCourseUpdated = "course.updated"
+	eventScanFailed    = "scan.failed"
+)
+
+// ServerEvent is one message on the /api/events stream
+type ServerEvent struct {
+	ID          int64     `json:"id,omitempty"`
+	Type        string    `json:"type"`
+	Week        int       `json:"week,omitempty"`
+	SectionWeek int       `json:"section_week,omitempty"`
+	Section     string    `json:"section,omitempty"`
+	File        string    `json:"file,omitempty"` // Relative to the lessons directory
+	Error       string    `json:"error,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+// eventHub fans events out to SSE subscribers and keeps a short backlog for
+// Last-Event-ID resume. IDs start from the process start time in
+// milliseconds so IDs from an earlier run are always older than the backlog.
+type eventHub struct {
+	mutex       sync.Mutex
+	nextID      int64
+	buffer      []ServerEvent
+	subscribers map[chan ServerEvent]bool
+	closed      bool
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{
+		nextID:      time.Now().UnixMilli() * 1000,
+		subscribers: make(map[chan ServerEvent]bool),
+	}
+}
+
+func (h *eventHub) publish(event ServerEvent) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.closed {
+		return
+	}
+
+	h.nextID++
+	event.ID = h.nextID
+	event.Time = time.Now()
+
+	h.buffer = append(h.buffer, event)
+	if len(h.buffer) > maxBufferedEvents {
+		h.buffer = h.buffer[len(h.buffer)-maxBufferedEvents:]
+	}
+
+	for ch := range h.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// A subscriber this far behind reconnects and resumes from
+			// its Last-Event-ID
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe registers a subscriber and returns the buffered events after
+// lastID. complete is false when lastID is older than the backlog, in which
+// case the client should refresh everything.
+func (h *eventHub) subscribe(lastID int64) (chan ServerEvent, []ServerEvent, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	ch := make(chan ServerEvent, subscriberBuffer)
+	if h.closed {
+		close(ch)
+		return ch, nil, true
+	}
+	h.subscribers[ch] = true
+
+	if lastID == 0 || lastID >= h.nextID {
+		return ch, nil, lastID == 0 || lastID == h.nextID
+	}
+
+	var backlog []ServerEvent
+	for _, event := range h.buffer {
+		if event.ID > lastID {
+			backlog = append(backlog, event)
+		}
+	}
+	complete := len(h.buffer) > 0 && h.buffer[0].ID <= lastID+1
+	return ch, backlog, complete
+}
+
+func (h *eventHub) unsubscribe(ch chan ServerEvent) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.subscribers[ch] {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+}
+
+// close ends every open stream
+func (h *eventHub) close() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.closed = true
+	for ch := range h.subscribers {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+}
+
+// relativeLessonPath keeps absolute server paths out of events
+func (s *Server) relativeLessonPath(path string) string {
+	if rel, err := filepath.Rel(s.lessonsDir, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.Base(path)
+}
+
+// publishLessonChanges sends an event per change recorded by a rescan.
+// Caller must hold s.mutex.
+func (s *Server) publishLessonChanges(changes []LessonChange) {
+	for _, change := range changes {
+		event := ServerEvent{
+			Type:    eventLessonUpdated,
+			Week:    change.Week,
+			Section: change.Section,
+			File:    s.relativeLessonPath(change.FilePath),
+		}
+		if change.Kind == "removed" {
+			event.Type = eventLessonRemoved
+		}
+		if section, exists := s.sections[change.Section]; exists {
+			event.SectionWeek = change.Week - section.WeekStart + 1
+		}
+		s.events.publish(event)
+	}
+}
+
+// publishScanFailed reports a lesson or course file that could not be loaded,
+// to event subscribers and /readyz
+func (s *Server) publishScanFailed(path, section string, err error) {
+	s.health.recordError(s.relativeLessonPath(path), err)
+	s.events.publish(ServerEvent{
+		Type:    eventScanFailed,
+		Section: section,
+		File:    s.relativeLessonPath(path),
+		Error:   err.Error(),
+	})
+}
+
+func writeServerEvent(w http.ResponseWriter, event ServerEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if event.ID != 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", event.ID); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
+
+// handleEvents streams content change events. ?section= limits lesson
+// events to one section; course.updated and scan.failed are always sent.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	sectionID := r.URL.Query().Get("section")
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("lastEventId")
+	}
+	since, _ := strconv.ParseInt(lastID, 10, 64)
+
+	ch, backlog, complete := s.events.subscribe(since)
+	defer s.events.unsubscribe(ch)
+	s.metrics.eventSubscribers.Add(1)
+	defer s.metrics.eventSubscribers.Add(-1)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	// Streams outlive the server's WriteTimeout; heartbeats detect dead peers
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		slog.Warn("could not clear write deadline for event stream", "error", err)
+	}
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprint(w, "retry: 3000\n\n")
+	if !complete {
+		// Missed events are gone, so ask the client to reload everything
+		// (without an id, so the client keeps its place in the stream)
+		writeServerEvent(w, ServerEvent{Type: eventCourseUpdated, Time: time.Now()})
+	}
+	matches := func(event ServerEvent) bool {
+		return sectionID == "" || event.Section == "" || event.Section == sectionID
+	}
+	for _, event := range backlog {
+		if matches(event) {
+			writeServerEvent(w, event)
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, open := <-ch:
+			if !open {
+				return
+			}
+			if !matches(event) {
+				continue
+			}
+			if err := writeServerEvent(w, event); err != nil {
+				slog.Debug("event stream write failed", "error", err)
+				return
+			}
+			flusher.Flush()
+		case now := <-heartbeat.C:
+			// No id, so heartbeats never move the client's Last-Event-ID
+			if _, err := fmt.Fprintf(w, "event: heartbeat\ndata: {\"time\":%q}\n\n", now.Format(time.RFC3339)); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
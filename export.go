@@ -0,0 +1,171 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ExportManifest describes every file written by `course-app export api`
+type ExportManifest struct {
+	GeneratedAt time.Time             `json:"generated_at"`
+	Course      string                `json:"course"`
+	Files       []ExportManifestEntry `json:"files"`
+}
+
+type ExportManifestEntry struct {
+	Endpoint    string `json:"endpoint"`
+	File        string `json:"file"`
+	ContentType string `json:"content_type"`
+	Size        int    `json:"size"`
+	SHA256      string `json:"sha256"`
+}
+
+// exportEndpoints lists every GET endpoint the Astro build can fetch,
+// expanded for the sections and lessons currently loaded.
+func (s *Server) exportEndpoints() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	endpoints := []string{
+		"/api/course",
+		"/api/lessons",
+		"/api/syllabus",
+		"/api/sections",
+		"/api/calendar.ics",
+		"/api/feed.atom",
+		"/api/assignments",
+		"/api/announcements",
+	}
+
+	var weeks []int
+	for week := range s.lessons {
+		weeks = append(weeks, week)
+	}
+	sort.Ints(weeks)
+	for _, week := range weeks {
+		endpoints = append(endpoints, fmt.Sprintf("/api/lessons/%d", week))
+	}
+
+	var sectionIDs []string
+	for sectionID := range s.sections {
+		sectionIDs = append(sectionIDs, sectionID)
+	}
+	sort.Strings(sectionIDs)
+
+	for _, sectionID := range sectionIDs {
+		section := s.sections[sectionID]
+		base := "/api/sections/" + sectionID
+		endpoints = append(endpoints, base, base+"/syllabus", base+"/calendar.ics", base+"/feed.atom", base+"/assignments", base+"/announcements")
+
+		for _, lesson := range section.Lessons {
+			week := lesson.Week - section.WeekStart + 1
+			lessonBase := fmt.Sprintf("%s/week/%d", base, week)
+			endpoints = append(endpoints, lessonBase, lessonBase+"/toc", lessonBase+"/content", lessonBase+"/quiz")
+		}
+	}
+
+	return endpoints
+}
+
+// exportFileName maps an API path to a file path relative to the export directory
+func exportFileName(endpoint, contentType string) string {
+	name := strings.TrimPrefix(endpoint, "/api/")
+	switch {
+	case strings.HasPrefix(contentType, "application/json"):
+		name += ".json"
+	case strings.HasPrefix(contentType, "text/markdown"):
+		name += ".md"
+	}
+	return filepath.FromSlash(name)
+}
+
+// exportAPI renders every endpoint through the live router and writes the
+// responses under outDir, so the files match what the server would return.
+func (s *Server) exportAPI(outDir string) (*ExportManifest, error) {
+	handler := s.setupRoutes()
+
+	s.mutex.RLock()
+	manifest := &ExportManifest{
+		GeneratedAt: time.Now(),
+		Course:      s.course.Title,
+	}
+	s.mutex.RUnlock()
+
+	for _, endpoint := range s.exportEndpoints() {
+		req := httptest.NewRequest(http.MethodGet, endpoint, nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			// Calendars 404 until the course has a start date
+			slog.Info("skipping endpoint", "endpoint", endpoint, "status", rec.Code)
+			continue
+		}
+
+		body := rec.Body.Bytes()
+		contentType := rec.Header().Get("Content-Type")
+		file := exportFileName(endpoint, contentType)
+		target := filepath.Join(outDir, file)
+
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create export directory: %w", err)
+		}
+		if err := os.WriteFile(target, body, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", target, err)
+		}
+
+		sum := sha256.Sum256(body)
+		manifest.Files = append(manifest.Files, ExportManifestEntry{
+			Endpoint:    endpoint,
+			File:        filepath.ToSlash(file),
+			ContentType: contentType,
+			Size:        len(body),
+			SHA256:      hex.EncodeToString(sum[:]),
+		})
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(outDir, "manifest.json"), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	return manifest, nil
+}
+
+// runExport writes the API for lessonsDir as static files under outDir
+func runExport(lessonsDir, outDir string) error {
+	server, err := loadIndex(lessonsDir)
+	if err != nil {
+		return err
+	}
+	defer server.close()
+
+	if err := server.scanAnnouncements(); err != nil {
+		slog.Warn("failed to scan announcements", "error", err)
+	}
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	manifest, err := server.exportAPI(outDir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Exported %d API files to %s\n", len(manifest.Files), outDir)
+	return nil
+}
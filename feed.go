@@ -0,0 +1,249 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxLessonChanges = 100
+	maxFeedEntries   = 20
+)
+
+// LessonChange records a lesson added, updated or removed by a rescan
+type LessonChange struct {
+	Kind     string    `json:"kind"` // "added", "updated" or "removed"
+	Week     int       `json:"week"`
+	Section  string    `json:"section"`
+	FilePath string    `json:"file_path"`
+	Time     time.Time `json:"time"`
+}
+
+// recordLessonChanges diffs the previous and new lesson index and reports
+// whether anything changed. The initial scan (empty previous index) is not
+// recorded. Caller must hold s.mutex.
+func (s *Server) recordLessonChanges(oldLessons, newLessons map[int]*Lesson) bool {
+	if len(oldLessons) == 0 {
+		return len(newLessons) > 0
+	}
+
+	now := time.Now()
+	oldByPath := make(map[string]*Lesson)
+	for _, lesson := range oldLessons {
+		oldByPath[lesson.FilePath] = lesson
+	}
+
+	var changes []LessonChange
+	for _, lesson := range newLessons {
+		old, existed := oldByPath[lesson.FilePath]
+		delete(oldByPath, lesson.FilePath)
+
+		kind := ""
+		switch {
+		case !existed:
+			kind = "added"
+		case old.Checksum != lesson.Checksum || !old.CreatedAt.Equal(lesson.CreatedAt) || old.Week != lesson.Week:
+			kind = "updated"
+		}
+		if kind != "" {
+			changes = append(changes, LessonChange{Kind: kind, Week: lesson.Week, Section: lesson.SectionID, FilePath: lesson.FilePath, Time: now})
+		}
+	}
+	for _, lesson := range oldByPath {
+		changes = append(changes, LessonChange{Kind: "removed", Week: lesson.Week, Section: lesson.SectionID, FilePath: lesson.FilePath, Time: now})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Week < changes[j].Week
+	})
+
+	s.changes = append(s.changes, changes...)
+	if len(s.changes) > maxLessonChanges {
+		s.changes = s.changes[len(s.changes)-maxLessonChanges:]
+	}
+	s.publishLessonChanges(changes)
+	return len(changes) > 0
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomPerson  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Links      []atomLink     `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+	Summary    string         `xml:"summary"`
+}
+
+// lessonEntryID is derived from the lesson's path relative to the lessons
+// directory so it stays the same across restarts and rescans.
+func (s *Server) lessonEntryID(lesson *Lesson) string {
+	rel, err := filepath.Rel(s.lessonsDir, lesson.FilePath)
+	if err != nil {
+		rel = lesson.FilePath
+	}
+	sum := sha1.Sum([]byte(filepath.ToSlash(rel)))
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+var (
+	summaryHTMLTags   = regexp.MustCompile(`<[^>]+>`)
+	summaryMarkdown   = regexp.MustCompile("(?m)^#{1,6}\\s*|[*_`>]|!?\\[([^\\]]*)\\]\\([^)]*\\)")
+	summaryWhitespace = regexp.MustCompile(`\s+`)
+)
+
+// lessonSummary prefers the frontmatter description and otherwise strips
+// markup from the start of the lesson content.
+func lessonSummary(lesson *Lesson) string {
+	if strings.TrimSpace(lesson.Description) != "" {
+		return strings.TrimSpace(lesson.Description)
+	}
+
+	text := summaryHTMLTags.ReplaceAllString(lesson.Content, " ")
+	text = summaryMarkdown.ReplaceAllString(text, "$1")
+	text = strings.TrimSpace(summaryWhitespace.ReplaceAllString(text, " "))
+
+	runes := []rune(text)
+	if len(runes) > 300 {
+		text = string(runes[:300]) + "…"
+	}
+	return text
+}
+
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// buildFeed returns the most recently modified lessons as an Atom feed
+func (s *Server) buildFeed(r *http.Request, title string, lessons []*Lesson) atomFeed {
+	base := requestBaseURL(r)
+
+	s.mutex.RLock()
+	lastChange := make(map[string]string)
+	for _, change := range s.changes {
+		lastChange[change.FilePath] = change.Kind
+	}
+	author := s.course.Instructor
+	s.mutex.RUnlock()
+
+	sort.Slice(lessons, func(i, j int) bool {
+		return lessons[i].CreatedAt.After(lessons[j].CreatedAt)
+	})
+	if len(lessons) > maxFeedEntries {
+		lessons = lessons[:maxFeedEntries]
+	}
+
+	feed := atomFeed{
+		ID:     "urn:course-app:feed:" + strings.TrimPrefix(r.URL.Path, "/api/"),
+		Title:  title,
+		Author: atomPerson{Name: author},
+		Links: []atomLink{
+			{Href: base + r.URL.Path, Rel: "self", Type: "application/atom+xml"},
+			{Href: base + "/"},
+		},
+		Updated: time.Unix(0, 0).UTC().Format(time.RFC3339),
+	}
+
+	for i, lesson := range lessons {
+		if i == 0 {
+			feed.Updated = lesson.CreatedAt.UTC().Format(time.RFC3339)
+		}
+
+		entryTitle := fmt.Sprintf("Week %d: %s", lesson.Week, lesson.Title)
+		if kind := lastChange[lesson.FilePath]; kind != "" {
+			entryTitle = fmt.Sprintf("[%s] %s", strings.ToUpper(kind[:1])+kind[1:], entryTitle)
+		}
+
+		entry := atomEntry{
+			ID:      s.lessonEntryID(lesson),
+			Title:   entryTitle,
+			Updated: lesson.CreatedAt.UTC().Format(time.RFC3339),
+			Links:   []atomLink{{Href: fmt.Sprintf("%s/lessons/%d", base, lesson.Week)}},
+			Summary: lessonSummary(lesson),
+		}
+		if lesson.SectionID != "" {
+			entry.Categories = []atomCategory{{Term: lesson.SectionID, Label: lesson.SectionName}}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	return feed
+}
+
+func writeFeed(w http.ResponseWriter, feed atomFeed) {
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	enc.Encode(feed)
+}
+
+func (s *Server) handleFeed(w http.ResponseWriter, r *http.Request) {
+	s.mutex.RLock()
+	title := s.course.Title + " - Lesson Updates"
+	var lessons []*Lesson
+	for _, lesson := range s.lessons {
+		lessons = append(lessons, lesson)
+	}
+	s.mutex.RUnlock()
+
+	writeFeed(w, s.buildFeed(r, title, lessons))
+}
+
+func (s *Server) handleSectionFeed(w http.ResponseWriter, r *http.Request) {
+	sectionID := mux.Vars(r)["section"]
+
+	s.mutex.RLock()
+	section, exists := s.sections[sectionID]
+	var title string
+	var lessons []*Lesson
+	if exists {
+		title = s.course.Title + " - " + section.Name + " Updates"
+		lessons = append(lessons, section.Lessons...)
+	}
+	s.mutex.RUnlock()
+
+	if !exists {
+		http.Error(w, "Section not found", http.StatusNotFound)
+		return
+	}
+
+	writeFeed(w, s.buildFeed(r, title, lessons))
+}
@@ -0,0 +1,491 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// GradeEntry is one student's score on one assignment
+type GradeEntry struct {
+	StudentID    string    `json:"student_id"`
+	AssignmentID string    `json:"assignment_id"`
+	Score        float64   `json:"score"`
+	MaxPoints    float64   `json:"max_points"`
+	Comment      string    `json:"comment,omitempty"`
+	GradedAt     time.Time `json:"graded_at"`
+}
+
+type CategoryGrade struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Weight   float64  `json:"weight"`
+	Earned   float64  `json:"earned"`
+	Possible float64  `json:"possible"`
+	Percent  *float64 `json:"percent"` // nil until something in the category is graded
+}
+
+type SectionGrade struct {
+	StudentID  string          `json:"student_id"`
+	Section    string          `json:"section"`
+	Categories []CategoryGrade `json:"categories"`
+	Percent    *float64        `json:"percent"`
+	Graded     int             `json:"graded"`
+}
+
+// gradebookStore records scores per student per assignment in gradebook.json
+type gradebookStore struct {
+	mutex  sync.RWMutex
+	store  *jsonStore
+	grades map[string]map[string]*GradeEntry // student -> assignment -> grade
+}
+
+func openGradebookStore(path string) (*gradebookStore, error) {
+	g := &gradebookStore{grades: make(map[string]map[string]*GradeEntry)}
+	store, err := openJSONStore(path, &g.grades)
+	if err != nil {
+		return nil, err
+	}
+	g.store = store
+	return g, nil
+}
+
+// flush writes the store one last time before shutdown
+func (g *gradebookStore) flush() error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.store.save(g.grades)
+}
+
+func (g *gradebookStore) record(entry *GradeEntry) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	student, hadStudent := g.grades[entry.StudentID]
+	if !hadStudent {
+		student = make(map[string]*GradeEntry)
+		g.grades[entry.StudentID] = student
+	}
+	previous, hadPrevious := student[entry.AssignmentID]
+	student[entry.AssignmentID] = entry
+
+	if err := g.store.save(g.grades); err != nil {
+		switch {
+		case hadPrevious:
+			student[entry.AssignmentID] = previous
+		case hadStudent:
+			delete(student, entry.AssignmentID)
+		default:
+			delete(g.grades, entry.StudentID)
+		}
+		return err
+	}
+	return nil
+}
+
+func (g *gradebookStore) remove(studentID, assignmentID string) (bool, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	student := g.grades[studentID]
+	previous, exists := student[assignmentID]
+	if !exists {
+		return false, nil
+	}
+	delete(student, assignmentID)
+	if len(student) == 0 {
+		delete(g.grades, studentID)
+	}
+
+	if err := g.store.save(g.grades); err != nil {
+		student[assignmentID] = previous
+		g.grades[studentID] = student
+		return true, err
+	}
+	return true, nil
+}
+
+// forStudent returns a copy of the student's grades keyed by assignment
+func (g *gradebookStore) forStudent(studentID string) map[string]GradeEntry {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	result := make(map[string]GradeEntry)
+	for id, entry := range g.grades[studentID] {
+		result[id] = *entry
+	}
+	return result
+}
+
+func (g *gradebookStore) students() []string {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	students := make([]string, 0, len(g.grades))
+	for studentID := range g.grades {
+		students = append(students, studentID)
+	}
+	sort.Strings(students)
+	return students
+}
+
+// validateAssessmentWeights checks category IDs are unique and weights sum to 100
+func validateAssessmentWeights(categories []AssessmentCategory) error {
+	total := 0.0
+	seen := make(map[string]bool)
+	for _, c := range categories {
+		if c.Weight < 0 {
+			return fmt.Errorf("category %q has negative weight", c.ID)
+		}
+		if seen[c.ID] {
+			return fmt.Errorf("duplicate category id %q", c.ID)
+		}
+		seen[c.ID] = true
+		total += c.Weight
+	}
+	if math.Abs(total-100) > 0.01 {
+		return fmt.Errorf("assessment weights sum to %g%%, expected 100%%", total)
+	}
+	return nil
+}
+
+// validateSectionSyllabi logs sections whose assessment weights are invalid
+func validateSectionSyllabi() {
+	for sectionID, info := range sectionSyllabi {
+		if err := validateAssessmentWeights(info.AssessmentCategories); err != nil {
+			slog.Warn("invalid assessment weights", "section", sectionID, "error", err)
+		}
+	}
+}
+
+// uncategorizedCategory collects graded assignments whose category is
+// missing or unknown. It has no weight, so they are shown but never guessed
+// into the section percentage.
+const uncategorizedCategory = "uncategorized"
+
+// assignmentCategory returns the assignment's category, or
+// uncategorizedCategory when it is missing or not one of categories
+func assignmentCategory(a *Assignment, categories []AssessmentCategory) string {
+	for _, c := range categories {
+		if c.ID == a.Category {
+			return c.ID
+		}
+	}
+	return uncategorizedCategory
+}
+
+// uncategorizedAssignments lists the IDs of assignments that fall outside
+// the section's categories
+func uncategorizedAssignments(assignments []*Assignment, categories []AssessmentCategory) []string {
+	var ids []string
+	for _, a := range assignments {
+		if assignmentCategory(a, categories) == uncategorizedCategory {
+			ids = append(ids, a.ID)
+		}
+	}
+	return ids
+}
+
+func roundPercent(value float64) *float64 {
+	rounded := math.Round(value*100) / 100
+	return &rounded
+}
+
+// sectionAssignments returns the section's assignments sorted by due date
+func (s *Server) sectionAssignments(sectionID string) []*Assignment {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	assignments := make([]*Assignment, 0)
+	for _, a := range s.assignments {
+		if a.Section == sectionID {
+			assignments = append(assignments, a)
+		}
+	}
+	sortAssignments(assignments)
+	return assignments
+}
+
+// computeSectionGrade weights each category's earned/possible ratio over
+// graded work. Categories with nothing graded yet are left out and the
+// remaining weights are renormalized, giving a running grade.
+func computeSectionGrade(sectionID, studentID string, assignments []*Assignment, grades map[string]GradeEntry) SectionGrade {
+	categories := sectionSyllabi[sectionID].AssessmentCategories
+	result := SectionGrade{StudentID: studentID, Section: sectionID}
+
+	byCategory := make(map[string]*CategoryGrade)
+	for _, c := range categories {
+		result.Categories = append(result.Categories, CategoryGrade{ID: c.ID, Name: c.Name, Weight: c.Weight})
+	}
+	if len(uncategorizedAssignments(assignments, categories)) > 0 {
+		result.Categories = append(result.Categories, CategoryGrade{ID: uncategorizedCategory, Name: "Uncategorized"})
+	}
+	for i := range result.Categories {
+		byCategory[result.Categories[i].ID] = &result.Categories[i]
+	}
+
+	for _, a := range assignments {
+		entry, graded := grades[a.ID]
+		if !graded || entry.MaxPoints <= 0 {
+			continue
+		}
+		category := byCategory[assignmentCategory(a, categories)]
+		category.Earned += entry.Score
+		category.Possible += entry.MaxPoints
+		result.Graded++
+	}
+
+	weighted, weights := 0.0, 0.0
+	for i := range result.Categories {
+		c := &result.Categories[i]
+		if c.Possible == 0 {
+			continue
+		}
+		c.Percent = roundPercent(c.Earned / c.Possible * 100)
+		weighted += c.Weight * (c.Earned / c.Possible * 100)
+		weights += c.Weight
+	}
+	if weights > 0 {
+		result.Percent = roundPercent(weighted / weights)
+	}
+
+	return result
+}
+
+type gradeRequest struct {
+	Score     *float64 `json:"score"`
+	MaxPoints float64  `json:"max_points"`
+	Comment   string   `json:"comment"`
+}
+
+func (s *Server) handleRecordGrade(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	studentID, assignmentID := vars["student"], vars["assignment"]
+
+	if !studentIDPattern.MatchString(studentID) {
+		http.Error(w, "Invalid student id", http.StatusBadRequest)
+		return
+	}
+
+	s.mutex.RLock()
+	assignment, exists := s.assignments[assignmentID]
+	s.mutex.RUnlock()
+
+	if !exists {
+		http.Error(w, "Assignment not found", http.StatusNotFound)
+		return
+	}
+
+	var req gradeRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil || req.Score == nil {
+		http.Error(w, "Expected JSON body with a score", http.StatusBadRequest)
+		return
+	}
+
+	maxPoints := assignment.Points
+	if req.MaxPoints > 0 {
+		maxPoints = req.MaxPoints
+	}
+	if maxPoints <= 0 {
+		http.Error(w, "Assignment has no points; provide max_points", http.StatusBadRequest)
+		return
+	}
+	if *req.Score < 0 {
+		http.Error(w, "Score cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	entry := &GradeEntry{
+		StudentID:    studentID,
+		AssignmentID: assignmentID,
+		Score:        *req.Score,
+		MaxPoints:    maxPoints,
+		Comment:      req.Comment,
+		GradedAt:     time.Now(),
+	}
+	if err := s.gradebook.record(entry); err != nil {
+		slog.Error("failed to record grade", "student", studentID, "assignment", assignmentID, "error", err)
+		http.Error(w, "Failed to record grade", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entry)
+}
+
+func (s *Server) handleDeleteGrade(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	removed, err := s.gradebook.remove(vars["student"], vars["assignment"])
+	if err != nil {
+		slog.Error("failed to remove grade", "student", vars["student"], "assignment", vars["assignment"], "error", err)
+		http.Error(w, "Failed to remove grade", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		http.Error(w, "Grade not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// gradebookSection resolves the section and its syllabus categories
+func (s *Server) gradebookSection(w http.ResponseWriter, r *http.Request) (string, bool) {
+	sectionID := mux.Vars(r)["section"]
+
+	s.mutex.RLock()
+	_, exists := s.sections[sectionID]
+	s.mutex.RUnlock()
+
+	if !exists {
+		http.Error(w, "Section not found", http.StatusNotFound)
+		return "", false
+	}
+	if _, hasSyllabus := sectionSyllabi[sectionID]; !hasSyllabus {
+		http.Error(w, "Section has no assessment categories", http.StatusNotFound)
+		return "", false
+	}
+	return sectionID, true
+}
+
+func (s *Server) handleSectionGradebook(w http.ResponseWriter, r *http.Request) {
+	sectionID, ok := s.gradebookSection(w, r)
+	if !ok {
+		return
+	}
+
+	categories := sectionSyllabi[sectionID].AssessmentCategories
+	assignments := s.sectionAssignments(sectionID)
+
+	response := struct {
+		Section       string               `json:"section"`
+		Categories    []AssessmentCategory `json:"categories"`
+		WeightsValid  bool                 `json:"weights_valid"`
+		WeightsError  string               `json:"weights_error,omitempty"`
+		Assignments   []*Assignment        `json:"assignments"`
+		Uncategorized []string             `json:"uncategorized,omitempty"` // Not counted in the percentage
+		StudentGrades []SectionGrade       `json:"students"`
+	}{
+		Section:       sectionID,
+		Categories:    categories,
+		WeightsValid:  true,
+		Assignments:   assignments,
+		Uncategorized: uncategorizedAssignments(assignments, categories),
+		StudentGrades: make([]SectionGrade, 0),
+	}
+	if err := validateAssessmentWeights(categories); err != nil {
+		response.WeightsValid = false
+		response.WeightsError = err.Error()
+	}
+
+	for _, studentID := range s.gradebook.students() {
+		grade := computeSectionGrade(sectionID, studentID, assignments, s.gradebook.forStudent(studentID))
+		if grade.Graded > 0 {
+			response.StudentGrades = append(response.StudentGrades, grade)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (s *Server) handleStudentSectionGrade(w http.ResponseWriter, r *http.Request) {
+	sectionID, ok := s.gradebookSection(w, r)
+	if !ok {
+		return
+	}
+	studentID := mux.Vars(r)["student"]
+	if !selfOrInstructor(r, studentID) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	grades := s.gradebook.forStudent(studentID)
+	grade := computeSectionGrade(sectionID, studentID, s.sectionAssignments(sectionID), grades)
+
+	response := struct {
+		SectionGrade
+		Grades map[string]GradeEntry `json:"grades"`
+	}{
+		SectionGrade: grade,
+		Grades:       grades,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleGradebookCSV exports one row per student with every assignment
+// score, each category percentage and the weighted section percentage
+func (s *Server) handleGradebookCSV(w http.ResponseWriter, r *http.Request) {
+	sectionID, ok := s.gradebookSection(w, r)
+	if !ok {
+		return
+	}
+
+	categories := sectionSyllabi[sectionID].AssessmentCategories
+	assignments := s.sectionAssignments(sectionID)
+
+	header := []string{"student_id"}
+	for _, a := range assignments {
+		header = append(header, a.ID)
+	}
+	for _, c := range categories {
+		header = append(header, fmt.Sprintf("%s (%g%%)", c.Name, c.Weight))
+	}
+	// Matches the extra category computeSectionGrade adds
+	if len(uncategorizedAssignments(assignments, categories)) > 0 {
+		header = append(header, "Uncategorized (0%)")
+	}
+	header = append(header, "section_percent")
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-grades.csv"`, sectionID))
+
+	out := csv.NewWriter(w)
+	out.Write(header)
+
+	formatPercent := func(p *float64) string {
+		if p == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*p, 'f', 2, 64)
+	}
+
+	for _, studentID := range s.gradebook.students() {
+		grades := s.gradebook.forStudent(studentID)
+		grade := computeSectionGrade(sectionID, studentID, assignments, grades)
+		if grade.Graded == 0 {
+			continue
+		}
+
+		row := []string{studentID}
+		for _, a := range assignments {
+			if entry, graded := grades[a.ID]; graded {
+				row = append(row, strconv.FormatFloat(entry.Score, 'f', -1, 64))
+			} else {
+				row = append(row, "")
+			}
+		}
+		for _, c := range grade.Categories {
+			row = append(row, formatPercent(c.Percent))
+		}
+		row = append(row, formatPercent(grade.Percent))
+		out.Write(row)
+	}
+
+	out.Flush()
+	if err := out.Error(); err != nil {
+		slog.Error("failed to write gradebook CSV", "error", err)
+	}
+}
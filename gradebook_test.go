@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// breakStore makes every later save of the store at path fail by putting a
+// non-empty directory where the file is renamed to
+func breakStore(t *testing.T, path string) {
+	t.Helper()
+	os.Remove(path)
+	if err := os.MkdirAll(filepath.Join(path, "blocker"), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGradebookRollsBackFailedSaves(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gradebook.json")
+	g, err := openGradebookStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.record(&GradeEntry{StudentID: "student1", AssignmentID: "hw1", Score: 7, MaxPoints: 10}); err != nil {
+		t.Fatal(err)
+	}
+	breakStore(t, path)
+
+	if err := g.record(&GradeEntry{StudentID: "student1", AssignmentID: "hw1", Score: 10, MaxPoints: 10}); err == nil {
+		t.Fatal("record succeeded without saving")
+	}
+	if got := g.forStudent("student1")["hw1"].Score; got != 7 {
+		t.Errorf("score after failed update = %v, want the previous 7", got)
+	}
+
+	if err := g.record(&GradeEntry{StudentID: "student1", AssignmentID: "hw2", Score: 5, MaxPoints: 10}); err == nil {
+		t.Fatal("record succeeded without saving")
+	}
+	if _, exists := g.forStudent("student1")["hw2"]; exists {
+		t.Error("unsaved new grade kept in memory")
+	}
+
+	if err := g.record(&GradeEntry{StudentID: "student2", AssignmentID: "hw1", Score: 5, MaxPoints: 10}); err == nil {
+		t.Fatal("record succeeded without saving")
+	}
+	if _, exists := g.grades["student2"]; exists {
+		t.Error("unsaved student kept in memory")
+	}
+
+	if removed, err := g.remove("student1", "hw1"); !removed || err == nil {
+		t.Fatalf("remove = %t, %v; want true and a save error", removed, err)
+	}
+	if got := g.forStudent("student1")["hw1"].Score; got != 7 {
+		t.Errorf("score after failed remove = %v, want 7", got)
+	}
+}
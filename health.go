@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// loadError is the most recent lesson, course or watcher failure
+type loadError struct {
+	Message string    `json:"message"`
+	File    string    `json:"file,omitempty"` // Relative to the lessons directory
+	Time    time.Time `json:"time"`
+}
+
+// healthState tracks what /readyz reports beyond the lesson index itself
+type healthState struct {
+	mutex        sync.Mutex
+	courseLoaded bool
+	watching     bool
+	lastError    *loadError
+}
+
+func (h *healthState) recordError(file string, err error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.lastError = &loadError{Message: err.Error(), File: file, Time: time.Now()}
+}
+
+func (h *healthState) setCourseLoaded() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.courseLoaded = true
+}
+
+func (h *healthState) setWatching(watching bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.watching = watching
+}
+
+type readinessCheck struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type readinessResponse struct {
+	Status        string                    `json:"status"`
+	Checks        map[string]readinessCheck `json:"checks"`
+	LastScan      *time.Time                `json:"last_scan,omitempty"`
+	LastError     *loadError                `json:"last_error,omitempty"`
+	Lessons       int                       `json:"lessons"`
+	Sections      int                       `json:"sections"`
+	Announcements int                       `json:"announcements"`
+	Uptime        float64                   `json:"uptime_seconds"`
+}
+
+func writeHealth(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// handleHealthz reports that the process is up and serving requests
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, map[string]interface{}{
+		"status":         "ok",
+		"uptime_seconds": time.Since(s.metrics.started).Seconds(),
+	})
+}
+
+// handleReadyz reports whether the course content is loaded and being
+// watched, answering 503 with the failing checks when it is not
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	s.health.mutex.Lock()
+	courseLoaded := s.health.courseLoaded
+	watching := s.health.watching
+	lastError := s.health.lastError
+	s.health.mutex.Unlock()
+
+	response := readinessResponse{
+		Status:    "ok",
+		Checks:    make(map[string]readinessCheck),
+		LastError: lastError,
+		Uptime:    time.Since(s.metrics.started).Seconds(),
+	}
+	check := func(name string, ok bool, message string) {
+		if ok {
+			response.Checks[name] = readinessCheck{OK: true}
+			return
+		}
+		response.Checks[name] = readinessCheck{Error: message}
+		response.Status = "unavailable"
+	}
+
+	check("course_info", courseLoaded, "course info has not loaded")
+
+	lastScan := s.metrics.lastScanSuccess.Load()
+	check("lessons_scanned", lastScan != 0, "lessons have not been scanned")
+	if lastScan != 0 {
+		scanned := time.Unix(0, lastScan)
+		response.LastScan = &scanned
+	}
+
+	_, err := os.ReadDir(s.lessonsDir)
+	check("lessons_dir", err == nil, errorMessage(err))
+
+	_, frontend := staticAssets["index.html"]
+	check("frontend", frontend, "frontend index.html is missing")
+
+	check("watcher", watching, "file watcher is not running")
+
+	s.mutex.RLock()
+	response.Lessons = len(s.lessons)
+	response.Sections = len(s.sections)
+	response.Announcements = len(s.announcements)
+	s.mutex.RUnlock()
+
+	status := http.StatusOK
+	if response.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	writeHealth(w, status, response)
+}
+
+func errorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -5,7 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/fs"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -17,7 +17,6 @@ import (
 	"time"
 
 	"github.com/fsnotify/fsnotify"
-	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"gopkg.in/yaml.v3"
 )
@@ -31,25 +30,53 @@ type Course struct {
 	Duration     string   `json:"duration" yaml:"duration"`
 	Instructor   string   `json:"instructor" yaml:"instructor"`
 	Requirements []string `json:"requirements" yaml:"requirements"`
+	StartDate    string   `json:"start_date,omitempty" yaml:"start_date"` // YYYY-MM-DD of week 1, day 1
+	ClassDays    []string `json:"class_days,omitempty" yaml:"class_days"` // Weekdays that Day 1..N fall on
 }
 
 type Lesson struct {
-	Week        int       `json:"week"`
-	Section     string    `json:"section"`      // New field
-	SectionName string    `json:"section_name"` // New field
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Content     string    `json:"content"`
-	CreatedAt   time.Time `json:"created_at"`
-	FilePath    string    `json:"file_path"`
-	FileSize    int64     `json:"file_size"`
+	Week        int           `json:"week"`
+	Section     string        `json:"section"`      // New field
+	SectionID   string        `json:"section_id"`   // Section directory the lesson is filed under
+	SectionName string        `json:"section_name"` // New field
+	Title       string        `json:"title"`
+	Description string        `json:"description"`
+	Content     string        `json:"content"`
+	CreatedAt   time.Time     `json:"created_at"`
+	FilePath    string        `json:"file_path"`
+	FileSize    int64         `json:"file_size"`
+	Due         string        `json:"due,omitempty"`
+	Days        []LessonDay   `json:"days,omitempty"`
+	Assignments []*Assignment `json:"assignments,omitempty"`
+	Quizzes     []*Quiz       `json:"-"` // Answer keys never leave the server
+	Checksum    string        `json:"-"` // Hash of the file contents
+}
+
+// setSection files the lesson, and the assignments and quizzes parsed from
+// it, under a section
+func (l *Lesson) setSection(id, name string) {
+	l.Section, l.SectionID, l.SectionName = id, id, name
+	for _, a := range l.Assignments {
+		a.Section = id
+	}
+	for _, quiz := range l.Quizzes {
+		quiz.Section = id
+	}
+}
+
+// LessonDay is a "Day N" block inside a weekly lesson
+type LessonDay struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
 }
 
 type LessonMetadata struct {
-	Title       string `yaml:"title"`
-	Description string `yaml:"description"`
-	Week        int    `yaml:"week"`
-	Section     string `yaml:"section"`
+	Title       string        `yaml:"title"`
+	Description string        `yaml:"description,omitempty"`
+	Week        int           `yaml:"week"`
+	Section     string        `yaml:"section,omitempty"`
+	Due         string        `yaml:"due,omitempty"`
+	Assignments []*Assignment `yaml:"assignments,omitempty"`
 }
 
 type Section struct {
@@ -62,12 +89,36 @@ type Section struct {
 }
 
 type Server struct {
-	lessonsDir string
-	course     Course
-	lessons    map[int]*Lesson     // Keep existing week-based mapping
-	sections   map[string]*Section // New section-based mapping
-	mutex      sync.RWMutex
-	watcher    *fsnotify.Watcher
+	lessonsDir    string
+	course        Course
+	lessons       map[int]*Lesson     // Keep existing week-based mapping
+	sections      map[string]*Section // New section-based mapping
+	mutex         sync.RWMutex
+	watcher       *fsnotify.Watcher
+	changes       []LessonChange // Recent lesson changes seen by rescans, newest last
+	announcements []*Announcement
+	events        *eventHub // Live change notifications for /api/events
+	metrics       *metrics
+	health        healthState
+	indexModified time.Time // Last lesson index or course info change, for Last-Modified
+	assignments   map[string]*Assignment
+	dataDir       string // Local stores: submissions, grades, progress, ...
+	submissions   *submissionStore
+	gradebook     *gradebookStore
+	quizResults   *quizResultStore
+	progress      *progressStore
+	accounts      *accountStore
+	questions     *qaStore
+	attendance    *attendanceStore
+	// Serializes check-and-write in the authoring API
+	authoringMutex sync.Mutex
+	// Read-only lesson routes stay anonymous unless this is false
+	publicContent bool
+	corsOrigins   []string
+	// Content-Security-Policy for front end pages; empty uses the default
+	contentSecurityPolicy string
+	// Generated local CA certificate, offered at /ca.pem; empty otherwise
+	caCertFile string
 }
 
 // Add these structs after your existing structs (after Section struct)
@@ -110,21 +161,17 @@ func NewServer(lessonsDir string) (*Server, error) {
 		return nil, fmt.Errorf("failed to create file watcher: %w", err)
 	}
 
-	server := &Server{
-		lessonsDir: lessonsDir,
-		lessons:    make(map[int]*Lesson),
-		sections:   make(map[string]*Section),
-		watcher:    watcher,
-	}
+	server := newIndexServer(lessonsDir)
+	server.watcher = watcher
 
 	// Add the lessons directory to the watcher if it exists
 	if _, err := os.Stat(lessonsDir); err == nil {
 		err = watcher.Add(lessonsDir)
 		if err != nil {
-			log.Printf("Warning: failed to watch lessons directory: %v", err)
+			slog.Warn("failed to watch lessons directory", "dir", lessonsDir, "error", err)
 		}
 	} else {
-		log.Printf("Lessons directory %s not found, creating it", lessonsDir)
+		slog.Info("lessons directory not found, creating it", "dir", lessonsDir)
 		os.MkdirAll(lessonsDir, 0755)
 		watcher.Add(lessonsDir)
 	}
@@ -132,8 +179,26 @@ func NewServer(lessonsDir string) (*Server, error) {
 	return server, nil
 }
 
+// newIndexServer returns a server with no file watcher, for commands that
+// only read the lessons directory
+func newIndexServer(lessonsDir string) *Server {
+	return &Server{
+		lessonsDir:    lessonsDir,
+		lessons:       make(map[int]*Lesson),
+		sections:      make(map[string]*Section),
+		assignments:   make(map[string]*Assignment),
+		dataDir:       "./data",
+		publicContent: true,
+		corsOrigins:   []string{"*"},
+		events:        newEventHub(),
+		metrics:       newMetrics(),
+	}
+}
+
 func (s *Server) startFileWatcher() {
+	s.health.setWatching(true)
 	go func() {
+		defer s.health.setWatching(false)
 		for {
 			select {
 			case event, ok := <-s.watcher.Events:
@@ -141,25 +206,54 @@ func (s *Server) startFileWatcher() {
 					return
 				}
 
-				if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) {
+				if event.Name == s.announcementsDir() || filepath.Dir(event.Name) == s.announcementsDir() {
+					// Announcements are small, so reload on any change including removal
+					if event.Name == s.announcementsDir() || strings.HasSuffix(strings.ToLower(event.Name), ".md") {
+						time.Sleep(100 * time.Millisecond)
+						if err := s.scanAnnouncements(); err != nil {
+							slog.Error("announcement rescan failed", "error", err)
+						}
+					}
+					continue
+				}
+
+				// A new section directory needs a rescan so it gets watched
+				if info, err := os.Stat(event.Name); event.Has(fsnotify.Create) && err == nil && info.IsDir() {
+					if err := s.scanLessons(); err != nil {
+						slog.Error("lesson rescan failed", "error", err)
+						s.publishScanFailed(s.lessonsDir, "", err)
+					}
+					continue
+				}
+
+				// Removals and renames matter too, or deleted lessons linger
+				if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) || event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
 					if strings.HasSuffix(strings.ToLower(event.Name), ".md") ||
 						strings.HasSuffix(strings.ToLower(event.Name), ".yaml") ||
 						strings.HasSuffix(strings.ToLower(event.Name), ".yml") {
 
-						log.Printf("Detected file change: %s", event.Name)
+						slog.Info("file changed", "file", event.Name, "op", event.Op.String())
 						time.Sleep(100 * time.Millisecond)
 
 						if strings.Contains(event.Name, "course.yaml") || strings.Contains(event.Name, "course.yml") {
 							if err := s.loadCourseInfo(); err != nil {
-								log.Printf("Error reloading course info: %v", err)
+								slog.Error("course info reload failed", "file", event.Name, "error", err)
+								s.publishScanFailed(event.Name, "", err)
+							} else if err := s.scanLessons(); err != nil {
+								// Rescan so due dates follow start_date changes
+								slog.Error("lesson rescan failed", "error", err)
+								s.publishScanFailed(s.lessonsDir, "", err)
+							} else {
+								s.events.publish(ServerEvent{Type: eventCourseUpdated})
 							}
 						}
 
 						if strings.HasSuffix(strings.ToLower(event.Name), ".md") {
 							if err := s.scanLessons(); err != nil {
-								log.Printf("Error rescanning lessons: %v", err)
+								slog.Error("lesson rescan failed", "error", err)
+								s.publishScanFailed(s.lessonsDir, "", err)
 							} else {
-								log.Printf("Lessons updated. Found %d lessons in %d sections", len(s.lessons), len(s.sections))
+								slog.Info("lessons updated", "lessons", len(s.lessons), "sections", len(s.sections))
 							}
 						}
 					}
@@ -169,7 +263,9 @@ func (s *Server) startFileWatcher() {
 				if !ok {
 					return
 				}
-				log.Printf("File watcher error: %v", err)
+				slog.Error("file watcher error", "error", err)
+				s.metrics.watcherErrors.Add(1)
+				s.health.recordError("", err)
 			}
 		}
 	}()
@@ -197,7 +293,9 @@ func (s *Server) loadCourseInfo() error {
 					"Manage career goals through creating effective resumes/CVs, developing interviewing skills, and setting goals.",
 				},
 			}
-			log.Printf("Using default course info (no course.yaml found)")
+			slog.Info("using default course info", "reason", "no course.yaml found")
+			s.indexModified = time.Now()
+			s.health.setCourseLoaded()
 			return nil
 		}
 	}
@@ -211,7 +309,9 @@ func (s *Server) loadCourseInfo() error {
 		return fmt.Errorf("failed to parse course file: %w", err)
 	}
 
-	log.Printf("Course info loaded: %s", s.course.Title)
+	slog.Info("course info loaded", "file", courseFile, "title", s.course.Title)
+	s.indexModified = time.Now()
+	s.health.setCourseLoaded()
 	return nil
 }
 
@@ -219,7 +319,8 @@ func (s *Server) scanLessons() error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	log.Printf("Scanning lessons directory: %s", s.lessonsDir)
+	start := time.Now()
+	slog.Debug("scanning lessons", "dir", s.lessonsDir)
 
 	newLessons := make(map[int]*Lesson)
 	newSections := make(map[string]*Section)
@@ -249,9 +350,12 @@ func (s *Server) scanLessons() error {
 	}
 
 	if _, err := os.Stat(s.lessonsDir); os.IsNotExist(err) {
-		log.Printf("Lessons directory doesn't exist, skipping scan")
+		slog.Info("lessons directory missing, skipping scan", "dir", s.lessonsDir)
+		s.metrics.scanCompleted(time.Since(start))
+		s.indexModified = time.Now()
 		s.lessons = newLessons
 		s.sections = newSections
+		s.assignments = make(map[string]*Assignment)
 		return nil
 	}
 
@@ -260,10 +364,17 @@ func (s *Server) scanLessons() error {
 		sectionPath := filepath.Join(s.lessonsDir, sectionID)
 
 		if _, err := os.Stat(sectionPath); os.IsNotExist(err) {
-			log.Printf("Section directory %s doesn't exist, skipping", sectionID)
+			slog.Debug("section directory missing, skipping", "section", sectionID)
 			continue
 		}
 
+		// fsnotify is not recursive, so watch each section directory too
+		if s.watcher != nil {
+			if err := s.watcher.Add(sectionPath); err != nil {
+				slog.Warn("failed to watch section directory", "section", sectionID, "error", err)
+			}
+		}
+
 		err := filepath.WalkDir(sectionPath, func(path string, d fs.DirEntry, err error) error {
 			if err != nil {
 				return err
@@ -275,21 +386,23 @@ func (s *Server) scanLessons() error {
 
 			lesson, err := s.parseLesson(path, sectionID, section.Name, section.WeekStart)
 			if err != nil {
-				log.Printf("Error parsing lesson %s: %v", path, err)
+				slog.Error("lesson parse failed", "file", path, "section", sectionID, "error", err)
+				s.metrics.parseFailures.Add(1)
+				s.publishScanFailed(path, sectionID, err)
 				return nil
 			}
 
 			if lesson.Week >= section.WeekStart && lesson.Week <= section.WeekEnd {
 				newLessons[lesson.Week] = lesson
 				section.Lessons = append(section.Lessons, lesson)
-				log.Printf("Added lesson for week %d in %s: %s", lesson.Week, sectionID, lesson.Title)
+				slog.Debug("lesson indexed", "week", lesson.Week, "section", sectionID, "title", lesson.Title, "file", path)
 			}
 
 			return nil
 		})
 
 		if err != nil {
-			log.Printf("Error scanning section %s: %v", sectionID, err)
+			slog.Error("section scan failed", "section", sectionID, "error", err)
 		}
 
 		// Sort lessons within section
@@ -304,7 +417,9 @@ func (s *Server) scanLessons() error {
 		for _, filePath := range legacyFiles {
 			lesson, err := s.parseLesson(filePath, "", "", 1)
 			if err != nil {
-				log.Printf("Error parsing legacy lesson %s: %v", filePath, err)
+				slog.Error("lesson parse failed", "file", filePath, "legacy", true, "error", err)
+				s.metrics.parseFailures.Add(1)
+				s.publishScanFailed(filePath, "", err)
 				continue
 			}
 
@@ -312,11 +427,10 @@ func (s *Server) scanLessons() error {
 				// Determine which section this lesson belongs to
 				for sectionID, section := range newSections {
 					if lesson.Week >= section.WeekStart && lesson.Week <= section.WeekEnd {
-						lesson.Section = sectionID
-						lesson.SectionName = section.Name
+						lesson.setSection(sectionID, section.Name)
 						newLessons[lesson.Week] = lesson
 						section.Lessons = append(section.Lessons, lesson)
-						log.Printf("Added legacy lesson for week %d: %s", lesson.Week, lesson.Title)
+						slog.Debug("lesson indexed", "week", lesson.Week, "section", sectionID, "title", lesson.Title, "file", filePath, "legacy", true)
 						break
 					}
 				}
@@ -324,9 +438,14 @@ func (s *Server) scanLessons() error {
 		}
 	}
 
-	log.Printf("Found %d valid lessons across %d sections", len(newLessons), len(newSections))
+	slog.Info("lesson scan complete", "lessons", len(newLessons), "sections", len(newSections), "duration", time.Since(start))
+	s.metrics.scanCompleted(time.Since(start))
+	if s.recordLessonChanges(s.lessons, newLessons) || s.indexModified.IsZero() {
+		s.indexModified = time.Now()
+	}
 	s.lessons = newLessons
 	s.sections = newSections
+	s.assignments = s.indexAssignments(newLessons)
 	return nil
 }
 
@@ -341,28 +460,50 @@ func (s *Server) parseLesson(filePath, sectionID, sectionName string, weekOffset
 		return nil, err
 	}
 
+	lesson, warnings, err := s.parseLessonContent(filePath, content, fileInfo.ModTime(), sectionID, sectionName, weekOffset)
+	if err != nil {
+		return nil, err
+	}
+	for _, warning := range warnings {
+		slog.Warn("lesson warning", "file", filePath, "warning", warning)
+	}
+	return lesson, nil
+}
+
+// parseLessonContent builds a lesson from file content without touching
+// disk, returning warnings for anything that had to be skipped. Frontmatter
+// that does not decode is an error: the lesson's title, week and
+// assignments would all be lost.
+func (s *Server) parseLessonContent(filePath string, content []byte, modTime time.Time, sectionID, sectionName string, weekOffset int) (*Lesson, []string, error) {
+	var warnings []string
+
 	contentStr := string(content)
 	lesson := &Lesson{
 		FilePath:    filePath,
-		CreatedAt:   fileInfo.ModTime(),
-		FileSize:    fileInfo.Size(),
+		CreatedAt:   modTime,
+		FileSize:    int64(len(content)),
+		Checksum:    lessonChecksum(content),
 		Section:     sectionID,
+		SectionID:   sectionID,
 		SectionName: sectionName,
 	}
 
-	if strings.HasPrefix(contentStr, "---") {
-		parts := strings.SplitN(contentStr, "---", 3)
-		if len(parts) >= 3 {
-			var metadata LessonMetadata
-			if err := yaml.Unmarshal([]byte(parts[1]), &metadata); err == nil {
-				lesson.Title = metadata.Title
-				lesson.Description = metadata.Description
-				lesson.Week = metadata.Week
-				lesson.Content = strings.TrimSpace(parts[2])
-				if metadata.Section != "" {
-					lesson.Section = metadata.Section
-				}
-			}
+	var assignments []*Assignment
+	metadata, body, found, err := splitFrontmatter(contentStr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if found {
+		lesson.Title = metadata.Title
+		lesson.Description = metadata.Description
+		lesson.Week = metadata.Week
+		lesson.Content = body
+		lesson.Due = metadata.Due
+		assignments = metadata.Assignments
+		// Frontmatter `section:` is only reported back; it is often just the
+		// number ("2"), so SectionID, from the directory, files the lesson
+		if metadata.Section != "" {
+			lesson.Section = metadata.Section
 		}
 	}
 
@@ -386,7 +527,85 @@ func (s *Server) parseLesson(filePath, sectionID, sectionName string, weekOffset
 		lesson.Content = contentStr
 	}
 
-	return lesson, nil
+	quizzes, quizWarnings := s.extractQuizzes(lesson)
+	lesson.Quizzes = quizzes
+	warnings = append(warnings, quizWarnings...)
+
+	lesson.Days = extractLessonDays(lesson.Content)
+
+	blockAssignments, err := parseAssignmentBlocks(lesson.Content)
+	if err != nil {
+		warnings = append(warnings, err.Error())
+	}
+	normalized, assignmentWarnings := s.normalizeAssignments(lesson, append(assignments, blockAssignments...))
+	lesson.Assignments = normalized
+	warnings = append(warnings, assignmentWarnings...)
+
+	return lesson, warnings, nil
+}
+
+// splitFrontmatter separates YAML frontmatter from the lesson body. found
+// reports whether the content starts a frontmatter block at all.
+func splitFrontmatter(content string) (LessonMetadata, string, bool, error) {
+	var metadata LessonMetadata
+	found, err := decodeFrontmatter(content, &metadata)
+	if err != nil || !found {
+		return metadata, content, found, err
+	}
+	_, body, _ := cutFrontmatter(content)
+	return metadata, body, true, nil
+}
+
+// cutFrontmatter returns the YAML between the leading "---" markers and the
+// trimmed body after them. ok is false when the block is never closed.
+func cutFrontmatter(content string) (frontmatter, body string, ok bool) {
+	parts := strings.SplitN(content, "---", 3)
+	if len(parts) < 3 {
+		return "", content, false
+	}
+	return parts[1], strings.TrimSpace(parts[2]), true
+}
+
+// decodeFrontmatter unmarshals the frontmatter of any Markdown file with a
+// leading "---" block into out
+func decodeFrontmatter(content string, out interface{}) (bool, error) {
+	if !strings.HasPrefix(content, "---") {
+		return false, nil
+	}
+	frontmatter, _, ok := cutFrontmatter(content)
+	if !ok {
+		return true, fmt.Errorf("unterminated frontmatter")
+	}
+	if err := yaml.Unmarshal([]byte(frontmatter), out); err != nil {
+		return true, fmt.Errorf("invalid frontmatter: %w", err)
+	}
+	return true, nil
+}
+
+var dayHeadingPattern = regexp.MustCompile(`(?i)^#{1,6}\s*day\s+(\d+)\s*[:\-–]?\s*(.*)$`)
+
+// extractLessonDays finds "### Day N: Title" headings in lesson content
+func extractLessonDays(content string) []LessonDay {
+	var days []LessonDay
+	seen := make(map[int]bool)
+
+	for _, line := range strings.Split(content, "\n") {
+		matches := dayHeadingPattern.FindStringSubmatch(strings.TrimSpace(line))
+		if len(matches) < 3 {
+			continue
+		}
+		number, err := strconv.Atoi(matches[1])
+		if err != nil || number < 1 || seen[number] {
+			continue
+		}
+		seen[number] = true
+		days = append(days, LessonDay{Number: number, Title: strings.TrimSpace(matches[2])})
+	}
+
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Number < days[j].Number
+	})
+	return days
 }
 
 func extractWeekFromFilename(filename string) int {
@@ -474,6 +693,25 @@ func (s *Server) handleSections(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Merge progress summaries for an identified student
+	studentID := s.requestStudentID(r)
+	if s.progress != nil && studentIDPattern.MatchString(studentID) {
+		progress := s.progress.forStudent(studentID)
+
+		type sectionWithProgress struct {
+			*Section
+			Progress *SectionProgress `json:"progress"`
+		}
+		withProgress := make([]sectionWithProgress, 0, len(sections))
+		for _, section := range sections {
+			withProgress = append(withProgress, sectionWithProgress{section, summarizeSectionProgress(section, progress)})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(withProgress)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(sections)
 }
@@ -531,6 +769,202 @@ func (s *Server) handleSectionLesson(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(lesson)
 }
 
+// SectionSyllabusInfo is the section-specific course information served
+// with /api/sections/{section}/syllabus
+type SectionSyllabusInfo struct {
+	CourseCode           string               `json:"course_code"`
+	Credits              string               `json:"credits"`
+	Prerequisites        string               `json:"prerequisites"`
+	Description          string               `json:"description"`
+	Objectives           []string             `json:"objectives"`
+	Topics               []string             `json:"topics"`
+	Assessment           []string             `json:"assessment"`
+	AssessmentCategories []AssessmentCategory `json:"assessment_categories"`
+	Resources            []string             `json:"resources"`
+}
+
+// AssessmentCategory is a weighted grading category; weights per section sum to 100
+type AssessmentCategory struct {
+	ID     string  `json:"id"`
+	Name   string  `json:"name"`
+	Weight float64 `json:"weight"`
+}
+
+var sectionSyllabi = map[string]SectionSyllabusInfo{
+	"section1-html-css": {
+		CourseCode:    "CIS 241 ART 225 CIS 291",
+		Credits:       "11.0 Credits",
+		Prerequisites: "CIS 100 or instructor permission",
+		Description:   "Website development using current HTML languages, approached from a source code perspective. Covers tags, forms, linked objects, current CSS, frames, tables, and an introduction to scripting.",
+		Objectives: []string{
+			"Perform content design and technical analysis on web applications and websites",
+			"Use current HTML to develop, debug, maintain, and document web applications and websites",
+			"Compare and contrast different browsers' effects on current HTML documents",
+			"Use current HTML forms, iframes, and tables",
+			"Create current HTML style through inline, embedded, and Cascading Style Sheets",
+		},
+		Topics: []string{
+			"HTML5 semantic elements and document structure",
+			"CSS fundamentals: selectors, properties, and values",
+			"CSS layout techniques: Flexbox and Grid",
+			"Responsive web design and media queries",
+			"Web accessibility principles and best practices",
+			"Form creation and validation",
+			"CSS animations and transitions",
+			"Browser compatibility and testing",
+			"Version control with Git and GitHub",
+			"Web development tools and workflow",
+			"Performance optimization basics",
+			"Final project: Complete responsive website",
+		},
+		AssessmentCategories: []AssessmentCategory{
+			{ID: "assignments", Name: "Weekly coding assignments", Weight: 40},
+			{ID: "midterm", Name: "Midterm project: Multi-page website", Weight: 20},
+			{ID: "final", Name: "Final project: Responsive portfolio site", Weight: 25},
+			{ID: "labs", Name: "Lab exercises and participation", Weight: 15},
+		},
+		Resources: []string{
+			"MAIN.GO - MDN Web Docs - HTML/CSS Reference  <a href='http://localhost:22022'/>main.go</a>",
+			"W3Schools - HTML/CSS Tutorials",
+			"Can I Use - Browser compatibility tables",
+			"CSS-Tricks - CSS techniques and guides",
+			"GitHub - Version control and project hosting",
+		},
+	},
+	"section2-javascript": {
+		CourseCode:    "CIS 242",
+		Credits:       "5.0 Credits",
+		Prerequisites: "CIS 241 with a minimum grade of 2.5 or instructor permission",
+		Description:   "Students will explore embedding, inline and external scripts, functions, form validation, loops, conditional statements, strings, numbers, and DHTML. Introduction to JavaScript Frameworks.",
+		Objectives: []string{
+			"Use object-oriented client-side scripting with well-formed web pages",
+			"Recognize client-side variables and data types and operations",
+			"Write client-side functions, event handlers, and control structures",
+			"Verify form data through scripting validation",
+			"Save state information through hidden fields, query-strings, and cookies",
+			"List concepts of server-side programming and Node.js",
+		},
+		Topics: []string{
+			"JavaScript fundamentals: variables, data types, operators",
+			"Functions and scope",
+			"DOM manipulation and event handling",
+			"Control structures: loops and conditionals",
+			"Arrays and objects",
+			"Form validation and user input handling",
+			"Asynchronous JavaScript: callbacks, promises, async/await",
+			"ES6+ features: arrow functions, destructuring, modules",
+			"Local storage and session management",
+			"Introduction to JavaScript frameworks",
+			"Debugging and testing techniques",
+			"Final project: Interactive web application",
+		},
+		AssessmentCategories: []AssessmentCategory{
+			{ID: "assignments", Name: "Weekly programming exercises", Weight: 35},
+			{ID: "midterm", Name: "Midterm exam: JavaScript fundamentals", Weight: 20},
+			{ID: "project", Name: "Interactive web app project", Weight: 30},
+			{ID: "labs", Name: "Lab work and code reviews", Weight: 15},
+		},
+
+		Resources: []string{
+			`<a href="https://developer.mozilla.org/en-US/docs/Web/JavaScript" target="_blank">MDN Web Docs - JavaScript Reference</a>`,
+			`<a href="https://javascript.info/" target="_blank">JavaScript.info - Modern JavaScript tutorial</a>`,
+			`<a href="https://www.w3schools.com/js/" target="_blank">W3Schools - JavaScript tutorials and examples</a>`,
+			`<a href="https://codepen.io/" target="_blank">CodePen - JavaScript code playground</a>`,
+			`<a href="https://developer.chrome.com/docs/devtools/" target="_blank">Chrome DevTools - Debugging and testing</a>`,
+		},
+	},
+	"section3-backend": {
+		CourseCode:    "CIS 243",
+		Credits:       "5.0 Credits",
+		Prerequisites: "CIS 242 with a minimum grade of 2.5 or instructor permission",
+		Description:   "Server-side scripting fundamentals including functions, logical structure, database connectivity, Object-Oriented principles, relational databases, and web frameworks.",
+		Objectives: []string{
+			"Understand difference between client-side and server-side scripting",
+			"Use appropriate script types to complete interactive websites with data repositories",
+			"Use Model, View, Controller (MVC) principles and architecture",
+			"Use operators including logical operators and variables in scripting language",
+			"Create procedures and reusable code in scripting language",
+			"Create websites using web frameworks",
+		},
+		Topics: []string{
+			"Node.js runtime and npm package management",
+			"Express.js framework and routing",
+			"Database design and MongoDB integration",
+			"RESTful API development",
+			"Authentication and authorization",
+			"Middleware and error handling",
+			"Data validation and sanitization",
+			"File uploads and processing",
+			"Environment configuration and deployment",
+			"Testing strategies for backend applications",
+			"Security best practices",
+			"Final project: Full-stack CRUD application",
+		},
+		AssessmentCategories: []AssessmentCategory{
+			{ID: "assignments", Name: "API development assignments", Weight: 40},
+			{ID: "database", Name: "Database design project", Weight: 20},
+			{ID: "project", Name: "Full-stack application", Weight: 25},
+			{ID: "documentation", Name: "Technical documentation and testing", Weight: 15},
+		},
+		Resources: []string{
+			"MAIN.GO - Node.js Documentation",
+			"Express.js Official Guide",
+			"MongoDB University courses",
+			"Postman - API testing and documentation",
+			"Heroku/Netlify - Deployment platforms",
+		},
+	},
+	"section4-react": {
+		CourseCode:    "CIS 244",
+		Credits:       "5.0 Credits",
+		Prerequisites: "CIS 241 with a minimum grade of 2.5 or instructor permission",
+		Description:   "Students learn to work with open-source JavaScript frameworks including React, AngularJS, Vue.js, and other commonly used frameworks to create and update website content.",
+		Objectives: []string{
+			"Determine business model of websites (B2B, B2C, e-commerce, social networking)",
+			"Compare and contrast top JavaScript frameworks",
+			"Develop and implement content using JavaScript frameworks",
+			"Develop responsive and accessible websites using current technologies",
+			"Create ongoing plan to maintain and update websites",
+		},
+		Topics: []string{
+			"React fundamentals: components, JSX, props",
+			"State management with hooks (useState, useEffect, useContext)",
+			"Component lifecycle and side effects",
+			"Event handling and forms in React",
+			"React Router for single-page applications",
+			"State management with Redux or Context API",
+			"API integration and data fetching",
+			"Testing React components",
+			"Performance optimization techniques",
+			"Deployment and build optimization",
+			"Modern React patterns and best practices",
+			"Capstone project: Production-ready React application",
+		},
+		AssessmentCategories: []AssessmentCategory{
+			{ID: "assignments", Name: "Component-building exercises", Weight: 35},
+			{ID: "midterm", Name: "Mid-term project: Multi-page React app", Weight: 25},
+			{ID: "capstone", Name: "Capstone project: Full-featured application", Weight: 30},
+			{ID: "code-quality", Name: "Code quality and documentation", Weight: 10},
+		},
+		Resources: []string{
+			"MAIN.GO - React Official Documentation",
+			"Create React App - Development environment",
+			"React Router documentation",
+			"Redux Toolkit - State management",
+			"Vercel/Netlify - React deployment platforms",
+		},
+	},
+}
+
+// assessmentLabels renders categories as the "Name (40%)" display strings
+func assessmentLabels(categories []AssessmentCategory) []string {
+	labels := make([]string, 0, len(categories))
+	for _, c := range categories {
+		labels = append(labels, fmt.Sprintf("%s (%g%%)", c.Name, c.Weight))
+	}
+	return labels
+}
+
 // Add this new handler to your main.go
 
 func (s *Server) handleSectionSyllabus(w http.ResponseWriter, r *http.Request) {
@@ -546,186 +980,11 @@ func (s *Server) handleSectionSyllabus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Section-specific course information
-	sectionInfo := map[string]struct {
-		CourseCode    string   `json:"course_code"`
-		Credits       string   `json:"credits"`
-		Prerequisites string   `json:"prerequisites"`
-		Description   string   `json:"description"`
-		Objectives    []string `json:"objectives"`
-		Topics        []string `json:"topics"`
-		Assessment    []string `json:"assessment"`
-		Resources     []string `json:"resources"`
-	}{
-		"section1-html-css": {
-			CourseCode:    "CIS 241 ART 225 CIS 291",
-			Credits:       "11.0 Credits",
-			Prerequisites: "CIS 100 or instructor permission",
-			Description:   "Website development using current HTML languages, approached from a source code perspective. Covers tags, forms, linked objects, current CSS, frames, tables, and an introduction to scripting.",
-			Objectives: []string{
-				"Perform content design and technical analysis on web applications and websites",
-				"Use current HTML to develop, debug, maintain, and document web applications and websites",
-				"Compare and contrast different browsers' effects on current HTML documents",
-				"Use current HTML forms, iframes, and tables",
-				"Create current HTML style through inline, embedded, and Cascading Style Sheets",
-			},
-			Topics: []string{
-				"HTML5 semantic elements and document structure",
-				"CSS fundamentals: selectors, properties, and values",
-				"CSS layout techniques: Flexbox and Grid",
-				"Responsive web design and media queries",
-				"Web accessibility principles and best practices",
-				"Form creation and validation",
-				"CSS animations and transitions",
-				"Browser compatibility and testing",
-				"Version control with Git and GitHub",
-				"Web development tools and workflow",
-				"Performance optimization basics",
-				"Final project: Complete responsive website",
-			},
-			Assessment: []string{
-				"Weekly coding assignments (40%)",
-				"Midterm project: Multi-page website (20%)",
-				"Final project: Responsive portfolio site (25%)",
-				"Lab exercises and participation (15%)",
-			},
-			Resources: []string{
-				"MAIN.GO - MDN Web Docs - HTML/CSS Reference  <a href='http://localhost:22022'/>main.go</a>",
-				"W3Schools - HTML/CSS Tutorials",
-				"Can I Use - Browser compatibility tables",
-				"CSS-Tricks - CSS techniques and guides",
-				"GitHub - Version control and project hosting",
-			},
-		},
-		"section2-javascript": {
-			CourseCode:    "CIS 242",
-			Credits:       "5.0 Credits",
-			Prerequisites: "CIS 241 with a minimum grade of 2.5 or instructor permission",
-			Description:   "Students will explore embedding, inline and external scripts, functions, form validation, loops, conditional statements, strings, numbers, and DHTML. Introduction to JavaScript Frameworks.",
-			Objectives: []string{
-				"Use object-oriented client-side scripting with well-formed web pages",
-				"Recognize client-side variables and data types and operations",
-				"Write client-side functions, event handlers, and control structures",
-				"Verify form data through scripting validation",
-				"Save state information through hidden fields, query-strings, and cookies",
-				"List concepts of server-side programming and Node.js",
-			},
-			Topics: []string{
-				"JavaScript fundamentals: variables, data types, operators",
-				"Functions and scope",
-				"DOM manipulation and event handling",
-				"Control structures: loops and conditionals",
-				"Arrays and objects",
-				"Form validation and user input handling",
-				"Asynchronous JavaScript: callbacks, promises, async/await",
-				"ES6+ features: arrow functions, destructuring, modules",
-				"Local storage and session management",
-				"Introduction to JavaScript frameworks",
-				"Debugging and testing techniques",
-				"Final project: Interactive web application",
-			},
-			Assessment: []string{
-				"Weekly programming exercises (35%)",
-				"Midterm exam: JavaScript fundamentals (20%)",
-				"Interactive web app project (30%)",
-				"Lab work and code reviews (15%)",
-			},
-
-			Resources: []string{
-				`<a href="https://developer.mozilla.org/en-US/docs/Web/JavaScript" target="_blank">MDN Web Docs - JavaScript Reference</a>`,
-				`<a href="https://javascript.info/" target="_blank">JavaScript.info - Modern JavaScript tutorial</a>`,
-				`<a href="https://www.w3schools.com/js/" target="_blank">W3Schools - JavaScript tutorials and examples</a>`,
-				`<a href="https://codepen.io/" target="_blank">CodePen - JavaScript code playground</a>`,
-				`<a href="https://developer.chrome.com/docs/devtools/" target="_blank">Chrome DevTools - Debugging and testing</a>`,
-			},
-		},
-		"section3-backend": {
-			CourseCode:    "CIS 243",
-			Credits:       "5.0 Credits",
-			Prerequisites: "CIS 242 with a minimum grade of 2.5 or instructor permission",
-			Description:   "Server-side scripting fundamentals including functions, logical structure, database connectivity, Object-Oriented principles, relational databases, and web frameworks.",
-			Objectives: []string{
-				"Understand difference between client-side and server-side scripting",
-				"Use appropriate script types to complete interactive websites with data repositories",
-				"Use Model, View, Controller (MVC) principles and architecture",
-				"Use operators including logical operators and variables in scripting language",
-				"Create procedures and reusable code in scripting language",
-				"Create websites using web frameworks",
-			},
-			Topics: []string{
-				"Node.js runtime and npm package management",
-				"Express.js framework and routing",
-				"Database design and MongoDB integration",
-				"RESTful API development",
-				"Authentication and authorization",
-				"Middleware and error handling",
-				"Data validation and sanitization",
-				"File uploads and processing",
-				"Environment configuration and deployment",
-				"Testing strategies for backend applications",
-				"Security best practices",
-				"Final project: Full-stack CRUD application",
-			},
-			Assessment: []string{
-				"API development assignments (40%)",
-				"Database design project (20%)",
-				"Full-stack application (25%)",
-				"Technical documentation and testing (15%)",
-			},
-			Resources: []string{
-				"MAIN.GO - Node.js Documentation",
-				"Express.js Official Guide",
-				"MongoDB University courses",
-				"Postman - API testing and documentation",
-				"Heroku/Netlify - Deployment platforms",
-			},
-		},
-		"section4-react": {
-			CourseCode:    "CIS 244",
-			Credits:       "5.0 Credits",
-			Prerequisites: "CIS 241 with a minimum grade of 2.5 or instructor permission",
-			Description:   "Students learn to work with open-source JavaScript frameworks including React, AngularJS, Vue.js, and other commonly used frameworks to create and update website content.",
-			Objectives: []string{
-				"Determine business model of websites (B2B, B2C, e-commerce, social networking)",
-				"Compare and contrast top JavaScript frameworks",
-				"Develop and implement content using JavaScript frameworks",
-				"Develop responsive and accessible websites using current technologies",
-				"Create ongoing plan to maintain and update websites",
-			},
-			Topics: []string{
-				"React fundamentals: components, JSX, props",
-				"State management with hooks (useState, useEffect, useContext)",
-				"Component lifecycle and side effects",
-				"Event handling and forms in React",
-				"React Router for single-page applications",
-				"State management with Redux or Context API",
-				"API integration and data fetching",
-				"Testing React components",
-				"Performance optimization techniques",
-				"Deployment and build optimization",
-				"Modern React patterns and best practices",
-				"Capstone project: Production-ready React application",
-			},
-			Assessment: []string{
-				"Component-building exercises (35%)",
-				"Mid-term project: Multi-page React app (25%)",
-				"Capstone project: Full-featured application (30%)",
-				"Code quality and documentation (10%)",
-			},
-			Resources: []string{
-				"MAIN.GO - React Official Documentation",
-				"Create React App - Development environment",
-				"React Router documentation",
-				"Redux Toolkit - State management",
-				"Vercel/Netlify - React deployment platforms",
-			},
-		},
-	}
-
-	info, exists := sectionInfo[sectionID]
+	info, exists := sectionSyllabi[sectionID]
 	if !exists {
-		info = sectionInfo["section1-html-css"] // Default fallback
+		info = sectionSyllabi["section1-html-css"] // Default fallback
 	}
+	info.Assessment = assessmentLabels(info.AssessmentCategories)
 
 	// Combine section data with syllabus info
 	response := struct {
@@ -758,7 +1017,7 @@ func (s *Server) handleSyllabus(w http.ResponseWriter, r *http.Request) {
 		Lessons:        s.lessons,
 		Sections:       s.sections,
 		Weeks:          make([]int, 0),
-		LastUpdated:    time.Now(),
+		LastUpdated:    s.indexModified,
 		TotalFiles:     len(s.lessons),
 	}
 
@@ -773,184 +1032,112 @@ func (s *Server) handleSyllabus(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(syllabus)
 }
 
-// Keep your existing handleStatic function as-is
-func (s *Server) handleStatic(w http.ResponseWriter, r *http.Request) {
-	// Remove /static prefix
-	path := strings.TrimPrefix(r.URL.Path, "/static/")
-
-	// Remove leading slash if present
-	path = strings.TrimPrefix(path, "/")
-
-	// Default to index.html for root requests
-	if path == "" {
-		path = "index.html"
-	}
-
-	// Construct the embedded file path
-	embedPath := "lessons/frontend/dist/" + path
-
-	// DEBUG: Log what we're trying to serve
-	log.Printf("Requested path: %s", r.URL.Path)
-	log.Printf("Cleaned path: %s", path)
-	log.Printf("Embed path: %s", embedPath)
-
-	// Try to read the file from embedded filesystem
-	data, err := staticFiles.ReadFile(embedPath)
-	if err != nil {
-		log.Printf("File not found: %s, serving index.html fallback", embedPath)
-		indexPath := embedPath + "/index.html"
-		log.Printf("Trying index path: %s", indexPath)
-
-		// If file not found, serve index.html (for SPA routing)
-		data, err = staticFiles.ReadFile(indexPath)
-		if err != nil {
-			log.Printf("Index file not found: %s, serving root index.html fallback", indexPath)
-
-			// If still not found, serve root index.html (for SPA routing)
-			data, err = staticFiles.ReadFile("lessons/frontend/dist/index.html")
-			if err != nil {
-				http.NotFound(w, r)
-				return
-			}
-		} else {
-			log.Printf("Index file found: %s", indexPath)
-		}
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		w.Write(data)
-		return
-	}
-
-	// Set appropriate content type
-	if strings.HasSuffix(path, ".css") {
-		log.Printf("Setting CSS content type")
-		w.Header().Set("Content-Type", "text/css; charset=utf-8")
-	} else if strings.HasSuffix(path, ".js") {
-		w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
-	} else if strings.HasSuffix(path, ".html") {
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	} else if strings.HasSuffix(path, ".json") {
-		w.Header().Set("Content-Type", "application/json")
-	} else if strings.HasSuffix(path, ".png") {
-		w.Header().Set("Content-Type", "image/png")
-	} else if strings.HasSuffix(path, ".jpg") || strings.HasSuffix(path, ".jpeg") {
-		w.Header().Set("Content-Type", "image/jpeg")
-	} else if strings.HasSuffix(path, ".svg") {
-		w.Header().Set("Content-Type", "image/svg+xml")
-	} else if strings.HasSuffix(path, ".ico") {
-		w.Header().Set("Content-Type", "image/x-icon")
-	} else {
-		// Default content type for other files
-		w.Header().Set("Content-Type", "application/octet-stream")
-	}
-
-	w.Write(data)
-}
-
 func (s *Server) setupRoutes() http.Handler {
 	r := mux.NewRouter()
 
 	// Create API subrouter FIRST
 	api := r.PathPrefix("/api").Subrouter()
-	api.HandleFunc("/course", s.handleCourse).Methods("GET")
-	api.HandleFunc("/lessons", s.handleLessons).Methods("GET")
-	api.HandleFunc("/lessons/{week:[0-9]+}", s.handleLesson).Methods("GET")
-	api.HandleFunc("/syllabus", s.handleSyllabus).Methods("GET")
-	api.HandleFunc("/sections", s.handleSections).Methods("GET")
-	api.HandleFunc("/sections/{section}", s.handleSection).Methods("GET")
+	api.Use(compressResponse)
+	api.Use(s.sessionMiddleware)
+	api.Use(s.conditionalGET)
+	api.Handle("/course", s.readAccess(s.handleCourse)).Methods("GET", "HEAD")
+	api.Handle("/lessons", s.readAccess(s.handleLessons)).Methods("GET", "HEAD")
+	api.Handle("/lessons/{week:[0-9]+}", s.readAccess(s.handleLesson)).Methods("GET", "HEAD")
+	api.Handle("/syllabus", s.readAccess(s.handleSyllabus)).Methods("GET", "HEAD")
+	api.Handle("/sections", s.readAccess(s.handleSections)).Methods("GET", "HEAD")
+	api.Handle("/sections/{section}", s.readAccess(s.handleSection)).Methods("GET", "HEAD")
 	// Add this route in your setupRoutes function
-	api.HandleFunc("/sections/{section}/syllabus", s.handleSectionSyllabus).Methods("GET")
+	api.Handle("/sections/{section}/syllabus", s.readAccess(s.handleSectionSyllabus)).Methods("GET", "HEAD")
 
 	// ###### This would allow both URL patterns:
 	//       /api/sections/section1-html-css/week/5 (original)
 	// ##### /api/sections/section1-html-css/5 (shorter)
 
 	// api.HandleFunc("/sections/{section}/{week:[0-9]+}", s.handleSectionLesson).Methods("GET")
-	api.HandleFunc("/sections/{section}/week/{week:[0-9]+}", s.handleSectionLesson).Methods("GET")
-
-	api.HandleFunc("/sections/{section}/week/{week:[0-9]+}/toc", s.handleLessonTOC).Methods("GET")
-	api.HandleFunc("/sections/{section}/week/{week:[0-9]+}/content", s.handleLessonContent).Methods("GET")
+	api.Handle("/sections/{section}/week/{week:[0-9]+}", s.readAccess(s.handleSectionLesson)).Methods("GET", "HEAD")
+	api.Handle("/sections/{section}/week/{week:[0-9]+}", s.instructorOnly(s.handleCreateLesson)).Methods("POST")
+	api.Handle("/sections/{section}/week/{week:[0-9]+}", s.instructorOnly(s.handleUpdateLesson)).Methods("PUT")
+	api.Handle("/sections/{section}/week/{week:[0-9]+}", s.instructorOnly(s.handleDeleteLesson)).Methods("DELETE")
+	api.Handle("/preview", s.instructorOnly(s.handlePreview)).Methods("POST")
+	api.Handle("/events", s.readAccess(s.handleEvents)).Methods("GET")
+	api.Handle("/announcements", s.readAccess(s.handleAnnouncements)).Methods("GET", "HEAD")
+	api.Handle("/announcements", s.instructorOnly(s.handleCreateAnnouncement)).Methods("POST")
+	api.Handle("/announcements/{id}", s.instructorOnly(s.handleDeleteAnnouncement)).Methods("DELETE")
+	api.Handle("/sections/{section}/announcements", s.readAccess(s.handleSectionAnnouncements)).Methods("GET", "HEAD")
+	api.Handle("/sections/{section}/week/{week:[0-9]+}/questions", s.signedIn(s.handleLessonQuestions)).Methods("GET", "HEAD")
+	api.Handle("/sections/{section}/week/{week:[0-9]+}/questions", s.signedIn(s.handleAskQuestion)).Methods("POST")
+	api.Handle("/questions/unanswered", s.instructorOnly(s.handleUnansweredQuestions)).Methods("GET", "HEAD")
+	api.Handle("/questions/{id}/answers", s.signedIn(s.handleAnswerQuestion)).Methods("POST")
+	api.Handle("/questions/{id}/accept", s.signedIn(s.handleAcceptAnswer)).Methods("PUT")
+	api.Handle("/questions/{id}/moderation", s.instructorOnly(s.handleModerateQuestion)).Methods("PUT")
+	api.Handle("/questions/{id}", s.instructorOnly(s.handleDeleteQuestion)).Methods("DELETE")
+	api.Handle("/sections/{section}/week/{week:[0-9]+}/days/{day:[0-9]+}/attendance", s.instructorOnly(s.handleDayAttendance)).Methods("GET", "HEAD")
+	api.Handle("/sections/{section}/week/{week:[0-9]+}/days/{day:[0-9]+}/attendance", s.instructorOnly(s.handleRecordAttendance)).Methods("PUT")
+	api.Handle("/sections/{section}/attendance", s.instructorOnly(s.handleSectionAttendance)).Methods("GET", "HEAD")
+	api.Handle("/sections/{section}/attendance.csv", s.instructorOnly(s.handleAttendanceCSV)).Methods("GET", "HEAD")
+	api.Handle("/sections/{section}/attendance/{student}", s.signedIn(s.handleStudentAttendance)).Methods("GET", "HEAD")
+
+	api.Handle("/sections/{section}/week/{week:[0-9]+}/toc", s.readAccess(s.handleLessonTOC)).Methods("GET", "HEAD")
+	api.Handle("/sections/{section}/week/{week:[0-9]+}/content", s.readAccess(s.handleLessonContent)).Methods("GET", "HEAD")
+
+	// Calendar feeds
+	api.Handle("/calendar.ics", s.readAccess(s.handleCalendar)).Methods("GET", "HEAD")
+	api.Handle("/sections/{section}/calendar.ics", s.readAccess(s.handleSectionCalendar)).Methods("GET", "HEAD")
+
+	// Atom feeds of lesson updates
+	api.Handle("/feed.atom", s.readAccess(s.handleFeed)).Methods("GET", "HEAD")
+	api.Handle("/sections/{section}/feed.atom", s.readAccess(s.handleSectionFeed)).Methods("GET", "HEAD")
+
+	// Assignments
+	api.Handle("/assignments", s.readAccess(s.handleAssignments)).Methods("GET", "HEAD")
+	api.Handle("/sections/{section}/assignments", s.readAccess(s.handleSectionAssignments)).Methods("GET", "HEAD")
+
+	// Submissions
+	api.Handle("/assignments/{id}/submissions", s.signedIn(s.handleSubmitAssignment)).Methods("POST")
+	api.Handle("/assignments/{id}/submissions", s.instructorOnly(s.handleAssignmentSubmissions)).Methods("GET", "HEAD")
+	api.Handle("/submissions/{id}/download", s.instructorOnly(s.handleDownloadSubmission)).Methods("GET", "HEAD")
+
+	// Gradebook
+	api.Handle("/gradebook/{student}/{assignment}", s.instructorOnly(s.handleRecordGrade)).Methods("PUT")
+	api.Handle("/gradebook/{student}/{assignment}", s.instructorOnly(s.handleDeleteGrade)).Methods("DELETE")
+	api.Handle("/sections/{section}/gradebook", s.instructorOnly(s.handleSectionGradebook)).Methods("GET", "HEAD")
+	api.Handle("/sections/{section}/gradebook.csv", s.instructorOnly(s.handleGradebookCSV)).Methods("GET", "HEAD")
+	api.Handle("/sections/{section}/gradebook/{student}", s.signedIn(s.handleStudentSectionGrade)).Methods("GET", "HEAD")
+
+	// Quizzes
+	api.Handle("/sections/{section}/week/{week:[0-9]+}/quiz", s.readAccess(s.handleLessonQuizzes)).Methods("GET", "HEAD")
+	api.Handle("/sections/{section}/week/{week:[0-9]+}/quiz/{quiz}/attempts", s.signedIn(s.handleSubmitQuizAttempt)).Methods("POST")
+	api.Handle("/quizzes/{quiz}/attempts", s.instructorOnly(s.handleQuizAttempts)).Methods("GET", "HEAD")
+
+	// Student progress
+	api.Handle("/progress", s.signedIn(s.handleProgress)).Methods("GET", "HEAD")
+	api.Handle("/progress/sections/{section}/week/{week:[0-9]+}", s.signedIn(s.handleUpdateProgress)).Methods("PUT")
+
+	// Accounts and sessions
+	api.HandleFunc("/auth/login", s.handleLogin).Methods("POST")
+	api.HandleFunc("/auth/logout", s.handleLogout).Methods("POST")
+	api.Handle("/auth/me", s.signedIn(s.handleMe)).Methods("GET", "HEAD")
+	api.Handle("/auth/password", s.signedIn(s.handleChangePassword)).Methods("POST")
+	api.Handle("/users", s.instructorOnly(s.handleListUsers)).Methods("GET", "HEAD")
+	api.Handle("/users", s.instructorOnly(s.handleCreateUser)).Methods("POST")
+	api.Handle("/users/import", s.instructorOnly(s.handleImportRoster)).Methods("POST")
+	api.Handle("/users/{username}", s.instructorOnly(s.handleDeleteUser)).Methods("DELETE")
 
 	// Debug log
-	log.Println("API routes registered")
+	slog.Debug("API routes registered")
+
+	r.HandleFunc("/metrics", s.handleMetrics).Methods("GET", "HEAD")
+	r.HandleFunc("/healthz", s.handleHealthz).Methods("GET", "HEAD")
+	r.HandleFunc("/readyz", s.handleReadyz).Methods("GET", "HEAD")
+	if s.caCertFile != "" {
+		r.HandleFunc("/ca.pem", s.handleCACert).Methods("GET", "HEAD")
+	}
+	r.Use(routeTemplate)
 
 	// Static files handler - MUST be after API routes
 	r.PathPrefix("/").HandlerFunc(s.handleStatic)
 
-	// CORS
-	corsHandler := handlers.CORS(
-		handlers.AllowedOrigins([]string{"*"}),
-		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}),
-		handlers.AllowedHeaders([]string{"*"}),
-	)(r)
-
-	return corsHandler
-}
-func (s *Server) setupRoutesLegasy2() http.Handler {
-	r := mux.NewRouter()
-
-	// API routes MUST be first
-	api := r.PathPrefix("/api").Subrouter()
-	api.HandleFunc("/course", s.handleCourse).Methods("GET")
-	api.HandleFunc("/lessons", s.handleLessons).Methods("GET")
-	api.HandleFunc("/lessons/{week:[0-9]+}", s.handleLesson).Methods("GET")
-	api.HandleFunc("/syllabus", s.handleSyllabus).Methods("GET")
-	api.HandleFunc("/sections", s.handleSections).Methods("GET")
-	api.HandleFunc("/sections/{section}", s.handleSection).Methods("GET")
-	api.HandleFunc("/sections/{section}/week/{week:[0-9]+}", s.handleSectionLesson).Methods("GET")
-
-	// DEBUG: Print registered routes
-	r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
-		pathTemplate, err := route.GetPathTemplate()
-		if err == nil {
-			log.Printf("🔗 Registered route: %s", pathTemplate)
-		}
-		return nil
-	})
-
-	// Static handler MUST be last
-	// r.PathPrefix("/").HandlerFunc(s.handleStatic)
-
-	// Static files - use a more specific pattern that excludes /api
-	r.PathPrefix("/static/").HandlerFunc(s.handleStatic)
-	// Root
-	r.PathPrefix("/").HandlerFunc(s.handleStatic) // Everything except /api
-
-	return handlers.CORS(
-		handlers.AllowedOrigins([]string{"*"}),
-		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}),
-		handlers.AllowedHeaders([]string{"*"}),
-	)(r)
-}
-
-func (s *Server) setupRoutesLegacy() http.Handler {
-	r := mux.NewRouter()
-
-	// API routes
-	api := r.PathPrefix("/api").Subrouter()
-
-	// Legacy routes (maintain backward compatibility)
-	api.HandleFunc("/course", s.handleCourse).Methods("GET")
-	api.HandleFunc("/lessons", s.handleLessons).Methods("GET")
-	api.HandleFunc("/lessons/{week:[0-9]+}", s.handleLesson).Methods("GET")
-	api.HandleFunc("/syllabus", s.handleSyllabus).Methods("GET")
-
-	// New section-based routes
-	api.HandleFunc("/sections", s.handleSections).Methods("GET")
-	api.HandleFunc("/sections/{section}", s.handleSection).Methods("GET")
-	api.HandleFunc("/sections/{section}/week/{week:[0-9]+}", s.handleSectionLesson).Methods("GET")
-
-	// Serve static files and SPA routes
-	// r.PathPrefix("/").HandlerFunc(s.handleStatic)
-	r.PathPrefix("/").Handler(http.HandlerFunc(s.handleStatic))
-
-	// CORS middleware
-	corsHandler := handlers.CORS(
-		handlers.AllowedOrigins([]string{"*"}),
-		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}),
-		handlers.AllowedHeaders([]string{"*"}),
-	)(r)
-
-	return corsHandler
+	return s.accessLog(s.securityHeaders(s.corsMiddleware(r)))
 }
 
 // Add these methods to your Server struct (add after your existing methods)
@@ -1185,68 +1372,106 @@ func (s *Server) getDefaultTOCItems() []TOCItem {
 	}
 }
 func (s *Server) close() error {
+	s.events.close()
 	if s.watcher != nil {
 		return s.watcher.Close()
 	}
 	return nil
 }
 
-func main() {
-	lessonsDir := "./lessons"
-	port := ":8080"
-
-	if len(os.Args) > 1 {
-		lessonsDir = os.Args[1]
-	}
-	if len(os.Args) > 2 {
-		port = ":" + os.Args[2]
-	}
-
-	log.Println("Checking embedded files:")
-	err := fs.WalkDir(staticFiles, ".", func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if !d.IsDir() {
-			log.Printf("Embedded file: %s", path)
-		}
-		return nil
-	})
-	if err != nil {
-		log.Printf("Error walking embedded files: %v", err)
+// serveCourse loads the course and runs the web server until shutdown
+func serveCourse(cfg config) error {
+	if err := loadStaticAssets(cfg.Frontend); err != nil {
+		return err
 	}
 
 	// Create lessons directory if it doesn't exist
-	if err := os.MkdirAll(lessonsDir, 0755); err != nil {
-		log.Printf("Warning: failed to create lessons directory: %v", err)
+	if err := os.MkdirAll(cfg.LessonsDir, 0755); err != nil {
+		slog.Warn("failed to create lessons directory", "dir", cfg.LessonsDir, "error", err)
 	}
 
-	server, err := NewServer(lessonsDir)
+	server, err := NewServer(cfg.LessonsDir)
 	if err != nil {
-		log.Fatal("Failed to create server:", err)
+		return fmt.Errorf("failed to create server: %w", err)
 	}
-	defer server.close()
+	server.dataDir = cfg.DataDir
+	server.publicContent = cfg.PublicContent
+	server.corsOrigins = cfg.CORSOrigins
+	server.contentSecurityPolicy = cfg.ContentSecurityPolicy
 
 	// Load initial data
 	if err := server.loadCourseInfo(); err != nil {
-		log.Printf("Warning: failed to load course info: %v", err)
+		slog.Warn("failed to load course info", "error", err)
+		server.health.recordError("course.yaml", err)
 	}
 
 	if err := server.scanLessons(); err != nil {
-		log.Printf("Warning: failed to scan lessons: %v", err)
+		slog.Warn("failed to scan lessons", "error", err)
+		server.health.recordError("", err)
+	}
+	if err := server.scanAnnouncements(); err != nil {
+		slog.Warn("failed to scan announcements", "error", err)
+	}
+
+	validateSectionSyllabi()
+
+	if err := server.openStores(); err != nil {
+		server.close()
+		return fmt.Errorf("failed to open data stores: %w", err)
 	}
 
 	// Start file watcher
 	server.startFileWatcher()
 
-	log.Printf("Course Management System Server")
-	log.Printf("Lessons directory: %s", lessonsDir)
-	log.Printf("Found %d lessons in %d sections", len(server.lessons), len(server.sections))
-	log.Printf("Server starting on http://localhost%s", port)
-	log.Printf("Frontend: http://localhost%s", port)
-	log.Printf("API: http://localhost%s/api", port)
-	log.Printf("New Section API: http://localhost%s/api/sections", port)
+	var certs *certReloader
+	if cfg.TLS {
+		certFile, keyFile := cfg.TLSCert, cfg.TLSKey
+		if certFile == "" {
+			tlsDir := filepath.Join(cfg.DataDir, tlsDirName)
+			certFile, keyFile, err = ensureLocalCertificate(tlsDir, cfg.TLSHosts)
+			if err != nil {
+				server.close()
+				return fmt.Errorf("failed to set up local certificates: %w", err)
+			}
+			server.caCertFile = filepath.Join(tlsDir, caCertName)
+		}
+		if certs, err = newCertReloader(certFile, keyFile); err != nil {
+			server.close()
+			return err
+		}
+	}
+
+	addr := listenAddress(cfg.Listen)
+	scheme := "http://"
+	if cfg.TLS {
+		scheme = "https://"
+	}
+	url := scheme + addr
+	if strings.HasPrefix(addr, ":") {
+		url = scheme + "localhost" + addr
+	}
+	slog.Info("Course Management System Server",
+		"lessons_dir", cfg.LessonsDir,
+		"lessons", len(server.lessons),
+		"sections", len(server.sections),
+		"url", url,
+		"api", url+"/api")
+	if server.caCertFile != "" {
+		slog.Info("clients must trust the local CA, downloadable at /ca.pem", "file", server.caCertFile)
+	}
+
+	var redirectAddr string
+	if cfg.HTTPRedirect != "" {
+		redirectAddr = listenAddress(cfg.HTTPRedirect)
+	}
 
 	handler := server.setupRoutes()
-	log.Fatal(http.ListenAndServe(port, handler))
+	return server.serve(addr, handler, certs, redirectAddr)
+}
+
+func main() {
+	if err := runCLI(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
 }
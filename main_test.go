@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+const malformedLesson = "---\ntitle: [bad\nweek: 2\n---\n\nBody\n"
+
+func TestMalformedFrontmatterFailsToParse(t *testing.T) {
+	s := newIndexServer(t.TempDir())
+
+	lesson, _, err := s.parseLessonContent("week2.md", []byte(malformedLesson), time.Now(), "section1-html-css", "HTML/CSS Fundamentals", 1)
+	if err == nil {
+		t.Fatalf("parsed %q, want a frontmatter error", lesson.Title)
+	}
+
+	tests := []struct {
+		content string
+		wantErr bool
+	}{
+		{"---\ntitle: Open\n", true},
+		{"No frontmatter at all\n", false},
+		{"---\ntitle: Fine\n---\n\nBody\n", false},
+	}
+	for _, tt := range tests {
+		_, _, err := s.parseLessonContent("week2.md", []byte(tt.content), time.Now(), "section1-html-css", "HTML/CSS Fundamentals", 1)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("content %q: error %v, want error %t", tt.content, err, tt.wantErr)
+		}
+	}
+}
+
+func TestScanCountsMalformedLessons(t *testing.T) {
+	ts := newTestServer(t, map[string]string{
+		"section1-html-css/week1.md": "---\ntitle: Good\nweek: 1\n---\n\nBody\n",
+		"section1-html-css/week2.md": malformedLesson,
+	})
+
+	if failures := ts.metrics.parseFailures.Load(); failures != 1 {
+		t.Errorf("parseFailures = %d, want 1", failures)
+	}
+	if _, indexed := ts.lessons[2]; indexed {
+		t.Error("malformed lesson was indexed with default values")
+	}
+	if _, indexed := ts.lessons[1]; !indexed {
+		t.Error("valid lesson missing from the index")
+	}
+
+	ts.health.mutex.Lock()
+	lastError := ts.health.lastError
+	ts.health.mutex.Unlock()
+	if lastError == nil || !strings.Contains(lastError.File, "week2.md") {
+		t.Errorf("readiness last error = %+v, want the malformed file", lastError)
+	}
+
+	instructor := ts.login("instructor")
+	rec := ts.do(instructor, http.MethodPost, "/api/preview?section=section1-html-css", strings.NewReader(malformedLesson), nil)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("preview status %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestFrontmatterSectionIsReportedOnly(t *testing.T) {
+	ts := newTestServer(t, map[string]string{
+		"section2-javascript/week13.md": "---\ntitle: Variables\nweek: 13\nsection: 2\nassignments:\n" +
+			"  - id: hw1\n    title: Homework\n    points: 10\n    category: assignments\n---\n\nBody\n",
+		"week14.md": "---\ntitle: Legacy\nweek: 14\nsection: 2\n---\n\nBody\n",
+	})
+
+	tests := []struct {
+		week    int
+		section string
+	}{
+		{13, "2"},
+		{14, "section2-javascript"},
+	}
+	for _, tt := range tests {
+		lesson := ts.lessons[tt.week]
+		if lesson == nil {
+			t.Fatalf("week %d not indexed", tt.week)
+		}
+		if lesson.Section != tt.section || lesson.SectionID != "section2-javascript" {
+			t.Errorf("week %d: section %q, section_id %q; want %q and section2-javascript",
+				tt.week, lesson.Section, lesson.SectionID, tt.section)
+		}
+	}
+	if a := ts.assignments["hw1"]; a == nil || a.Section != "section2-javascript" {
+		t.Errorf("assignment = %+v, want it filed under section2-javascript", a)
+	}
+}
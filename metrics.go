@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// requestDurationBuckets are the histogram upper bounds in seconds
+var requestDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type requestKey struct {
+	route  string
+	method string
+	code   int
+}
+
+type durationKey struct {
+	route  string
+	method string
+}
+
+type histogram struct {
+	counts []uint64 // Per bucket, not cumulative; the last entry is +Inf
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) observe(seconds float64) {
+	i := sort.SearchFloat64s(requestDurationBuckets, seconds)
+	h.counts[i]++
+	h.sum += seconds
+	h.count++
+}
+
+// metrics collects counters for /metrics in the Prometheus text format
+type metrics struct {
+	started time.Time
+
+	mutex     sync.Mutex
+	requests  map[requestKey]uint64
+	durations map[durationKey]*histogram
+
+	scans            atomic.Uint64
+	lastScanDuration atomic.Int64 // Nanoseconds
+	lastScanSuccess  atomic.Int64 // Unix nanoseconds
+	watcherErrors    atomic.Uint64
+	parseFailures    atomic.Uint64
+	eventSubscribers atomic.Int64
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		started:   time.Now(),
+		requests:  make(map[requestKey]uint64),
+		durations: make(map[durationKey]*histogram),
+	}
+}
+
+// observeRequest records one finished request. route is the mux template,
+// or "unmatched" when no route matched.
+func (m *metrics) observeRequest(route, method string, code int, duration time.Duration) {
+	if route == "" {
+		route = "unmatched"
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.requests[requestKey{route, method, code}]++
+	key := durationKey{route, method}
+	h, exists := m.durations[key]
+	if !exists {
+		h = &histogram{counts: make([]uint64, len(requestDurationBuckets)+1)}
+		m.durations[key] = h
+	}
+	h.observe(duration.Seconds())
+}
+
+func (m *metrics) scanCompleted(duration time.Duration) {
+	m.scans.Add(1)
+	m.lastScanDuration.Store(int64(duration))
+	m.lastScanSuccess.Store(time.Now().UnixNano())
+}
+
+// routeTemplate is a mux middleware that tells the access log which route
+// served the request, so metrics are labelled by template rather than path
+func routeTemplate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if info := requestLogInfo(r); info != nil {
+			if route := mux.CurrentRoute(r); route != nil {
+				info.route, _ = route.GetPathTemplate()
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// labels renders a Prometheus label set from name/value pairs
+func labels(pairs ...string) string {
+	var parts []string
+	for i := 0; i+1 < len(pairs); i += 2 {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(pairs[i+1])
+		parts = append(parts, pairs[i]+`="`+value+`"`)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func writeMetricHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func (m *metrics) writeRequests(w io.Writer) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	requestKeys := make([]requestKey, 0, len(m.requests))
+	for key := range m.requests {
+		requestKeys = append(requestKeys, key)
+	}
+	sort.Slice(requestKeys, func(i, j int) bool {
+		a, b := requestKeys[i], requestKeys[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.code < b.code
+	})
+
+	writeMetricHeader(w, "course_http_requests_total", "counter", "HTTP requests by route template, method and status code.")
+	for _, key := range requestKeys {
+		fmt.Fprintf(w, "course_http_requests_total%s %d\n",
+			labels("route", key.route, "method", key.method, "code", strconv.Itoa(key.code)), m.requests[key])
+	}
+
+	durationKeys := make([]durationKey, 0, len(m.durations))
+	for key := range m.durations {
+		durationKeys = append(durationKeys, key)
+	}
+	sort.Slice(durationKeys, func(i, j int) bool {
+		if durationKeys[i].route != durationKeys[j].route {
+			return durationKeys[i].route < durationKeys[j].route
+		}
+		return durationKeys[i].method < durationKeys[j].method
+	})
+
+	writeMetricHeader(w, "course_http_request_duration_seconds", "histogram", "HTTP request latency by route template and method.")
+	for _, key := range durationKeys {
+		h := m.durations[key]
+		var cumulative uint64
+		for i, bound := range requestDurationBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(w, "course_http_request_duration_seconds_bucket%s %d\n",
+				labels("route", key.route, "method", key.method, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "course_http_request_duration_seconds_bucket%s %d\n",
+			labels("route", key.route, "method", key.method, "le", "+Inf"), h.count)
+		fmt.Fprintf(w, "course_http_request_duration_seconds_sum%s %s\n",
+			labels("route", key.route, "method", key.method), formatFloat(h.sum))
+		fmt.Fprintf(w, "course_http_request_duration_seconds_count%s %d\n",
+			labels("route", key.route, "method", key.method), h.count)
+	}
+}
+
+// handleMetrics serves the Prometheus text exposition format
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+
+	m := s.metrics
+	m.writeRequests(w)
+
+	s.mutex.RLock()
+	sectionIDs := make([]string, 0, len(s.sections))
+	for id := range s.sections {
+		sectionIDs = append(sectionIDs, id)
+	}
+	sort.Strings(sectionIDs)
+	lessonCounts := make([]int, len(sectionIDs))
+	for i, id := range sectionIDs {
+		lessonCounts[i] = len(s.sections[id].Lessons)
+	}
+	lessons := len(s.lessons)
+	announcements := len(s.announcements)
+	s.mutex.RUnlock()
+
+	writeMetricHeader(w, "course_lessons", "gauge", "Lessons in the index.")
+	fmt.Fprintf(w, "course_lessons %d\n", lessons)
+	writeMetricHeader(w, "course_section_lessons", "gauge", "Lessons in the index per section.")
+	for i, id := range sectionIDs {
+		fmt.Fprintf(w, "course_section_lessons%s %d\n", labels("section", id), lessonCounts[i])
+	}
+	writeMetricHeader(w, "course_sections", "gauge", "Sections in the index.")
+	fmt.Fprintf(w, "course_sections %d\n", len(sectionIDs))
+	writeMetricHeader(w, "course_announcements", "gauge", "Announcements loaded.")
+	fmt.Fprintf(w, "course_announcements %d\n", announcements)
+
+	writeMetricHeader(w, "course_scans_total", "counter", "Completed lesson scans.")
+	fmt.Fprintf(w, "course_scans_total %d\n", m.scans.Load())
+	writeMetricHeader(w, "course_scan_duration_seconds", "gauge", "Duration of the most recent lesson scan.")
+	fmt.Fprintf(w, "course_scan_duration_seconds %s\n", formatFloat(time.Duration(m.lastScanDuration.Load()).Seconds()))
+	writeMetricHeader(w, "course_last_successful_scan_timestamp_seconds", "gauge", "Unix time of the last successful lesson scan.")
+	fmt.Fprintf(w, "course_last_successful_scan_timestamp_seconds %s\n", formatFloat(float64(m.lastScanSuccess.Load())/1e9))
+	writeMetricHeader(w, "course_lesson_parse_failures_total", "counter", "Lesson files that failed to parse during scans.")
+	fmt.Fprintf(w, "course_lesson_parse_failures_total %d\n", m.parseFailures.Load())
+	writeMetricHeader(w, "course_watcher_errors_total", "counter", "Errors reported by the file watcher.")
+	fmt.Fprintf(w, "course_watcher_errors_total %d\n", m.watcherErrors.Load())
+	writeMetricHeader(w, "course_event_subscribers", "gauge", "Open /api/events streams.")
+	fmt.Fprintf(w, "course_event_subscribers %d\n", m.eventSubscribers.Load())
+
+	writeMetricHeader(w, "process_start_time_seconds", "gauge", "Unix time the process started.")
+	fmt.Fprintf(w, "process_start_time_seconds %s\n", formatFloat(float64(m.started.UnixNano())/1e9))
+	writeMetricHeader(w, "go_goroutines", "gauge", "Goroutines that currently exist.")
+	fmt.Fprintf(w, "go_goroutines %d\n", runtime.NumGoroutine())
+}
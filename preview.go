@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"time"
+)
+
+// LessonPreview is what a draft would produce if it were saved
+type LessonPreview struct {
+	Lesson    *Lesson      `json:"lesson"`
+	TOCItems  []TOCItem    `json:"tocItems"`
+	TOCSource string       `json:"tocSource"`
+	Quizzes   []PublicQuiz `json:"quizzes,omitempty"`
+	Warnings  []string     `json:"warnings"`
+}
+
+// lintLesson reports problems that parse cleanly but would leave the lesson
+// missing, misplaced or incomplete once saved
+func (s *Server) lintLesson(lesson *Lesson, content []byte, section *Section) []string {
+	var warnings []string
+
+	// parseLessonContent already rejected frontmatter that does not decode
+	metadata, _, found, _ := splitFrontmatter(string(content))
+	if !found {
+		warnings = append(warnings, "no frontmatter; title and week come from the filename")
+	} else if metadata.Title == "" {
+		warnings = append(warnings, fmt.Sprintf("no title in frontmatter; defaults to %q", lesson.Title))
+	}
+
+	if lesson.Week == 0 {
+		warnings = append(warnings, "week could not be determined from frontmatter or filename")
+	} else if section != nil && (lesson.Week < section.WeekStart || lesson.Week > section.WeekEnd) {
+		warnings = append(warnings, fmt.Sprintf("week %d is outside %s (weeks %d-%d) and would not be listed",
+			lesson.Week, section.ID, section.WeekStart, section.WeekEnd))
+	}
+
+	if _, ok := parseCourseDate(lesson.Due); lesson.Due != "" && !ok {
+		warnings = append(warnings, fmt.Sprintf("due %q is not a recognised date", lesson.Due))
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if existing, exists := s.lessons[lesson.Week]; exists && existing.FilePath != lesson.FilePath {
+		warnings = append(warnings, fmt.Sprintf("week %d is already provided by %s", lesson.Week, existing.FilePath))
+	}
+
+	for _, a := range lesson.Assignments {
+		if _, ok := parseCourseDate(a.Due); a.Due != "" && !ok {
+			warnings = append(warnings, fmt.Sprintf("assignment %q: due %q is not a recognised date", a.ID, a.Due))
+		}
+		if warning := assignmentCategoryWarning(a, lesson.SectionID); warning != "" {
+			warnings = append(warnings, warning)
+		}
+		if other, exists := s.assignments[a.ID]; exists && other.Week != lesson.Week {
+			warnings = append(warnings, fmt.Sprintf("assignment id %q is already used in week %d", a.ID, other.Week))
+		}
+	}
+
+	return warnings
+}
+
+// handlePreview parses a draft the same way scanLessons would without
+// writing it. ?section= places the draft in a section and ?filename= stands
+// in for the file name when the frontmatter has no week.
+func (s *Server) handlePreview(w http.ResponseWriter, r *http.Request) {
+	content, err := io.ReadAll(io.LimitReader(r.Body, maxLessonSize+1))
+	if err != nil {
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return
+	}
+	if len(content) > maxLessonSize {
+		http.Error(w, fmt.Sprintf("Lesson exceeds %d MB", maxLessonSize>>20), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	sectionID := r.URL.Query().Get("section")
+	filename := filepath.Base(r.URL.Query().Get("filename"))
+	if filename == "." || filename == "/" {
+		filename = "draft.md"
+	}
+
+	var section *Section
+	sectionName, weekOffset, filePath := "", 1, filepath.Join(s.lessonsDir, filename)
+	if sectionID != "" {
+		s.mutex.RLock()
+		section = s.sections[sectionID]
+		s.mutex.RUnlock()
+		if section == nil {
+			http.Error(w, "Section not found", http.StatusNotFound)
+			return
+		}
+		sectionName, weekOffset = section.Name, section.WeekStart
+		filePath = filepath.Join(s.lessonsDir, sectionID, filename)
+	}
+
+	lesson, warnings, err := s.parseLessonContent(filePath, content, time.Now(), sectionID, sectionName, weekOffset)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	warnings = append(warnings, s.lintLesson(lesson, content, section)...)
+
+	if section == nil && lesson.Week > 0 {
+		// Mirror scanLessons, which files legacy lessons by week range
+		s.mutex.RLock()
+		for id, candidate := range s.sections {
+			if lesson.Week >= candidate.WeekStart && lesson.Week <= candidate.WeekEnd {
+				lesson.setSection(id, candidate.Name)
+			}
+		}
+		s.mutex.RUnlock()
+	}
+
+	s.mutex.RLock()
+	course := s.course
+	s.mutex.RUnlock()
+	for _, a := range lesson.Assignments {
+		resolveAssignmentDue(a, course)
+	}
+
+	preview := LessonPreview{
+		Lesson:    lesson,
+		TOCItems:  s.extractTOCFromContent(lesson.Content),
+		TOCSource: "markdown",
+		Warnings:  warnings,
+	}
+	if len(preview.TOCItems) == 0 {
+		preview.TOCItems = s.getDefaultTOCItems()
+		preview.TOCSource = "default"
+	}
+	for _, quiz := range lesson.Quizzes {
+		preview.Quizzes = append(preview.Quizzes, quiz.public())
+	}
+	if preview.Warnings == nil {
+		preview.Warnings = []string{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preview)
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// LessonProgress is one student's progress through one weekly lesson
+type LessonProgress struct {
+	Week          int        `json:"week"`
+	Section       string     `json:"section"`
+	OpenedAt      *time.Time `json:"opened_at,omitempty"`
+	CompletedAt   *time.Time `json:"completed_at,omitempty"`
+	DaysOpened    []int      `json:"days_opened,omitempty"`
+	DaysCompleted []int      `json:"days_completed,omitempty"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// SectionProgress summarizes a student's progress through a section
+type SectionProgress struct {
+	LessonsTotal     int     `json:"lessons_total"`
+	LessonsOpened    int     `json:"lessons_opened"`
+	LessonsCompleted int     `json:"lessons_completed"`
+	DaysTotal        int     `json:"days_total"`
+	DaysCompleted    int     `json:"days_completed"`
+	Percent          float64 `json:"percent"`
+}
+
+// progressStore keeps progress per student per global week in progress.json
+type progressStore struct {
+	mutex    sync.RWMutex
+	store    *jsonStore
+	progress map[string]map[string]*LessonProgress // student -> week -> progress
+}
+
+func openProgressStore(path string) (*progressStore, error) {
+	p := &progressStore{progress: make(map[string]map[string]*LessonProgress)}
+	store, err := openJSONStore(path, &p.progress)
+	if err != nil {
+		return nil, err
+	}
+	p.store = store
+	return p, nil
+}
+
+// flush writes the store one last time before shutdown
+func (p *progressStore) flush() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.store.save(p.progress)
+}
+
+// clone copies the entry including its day lists, so callers can encode
+// it after the lock is released
+func (l LessonProgress) clone() LessonProgress {
+	l.DaysOpened = append([]int(nil), l.DaysOpened...)
+	l.DaysCompleted = append([]int(nil), l.DaysCompleted...)
+	return l
+}
+
+// forStudent returns a copy of the student's progress keyed by global week
+func (p *progressStore) forStudent(studentID string) map[int]LessonProgress {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	result := make(map[int]LessonProgress)
+	for key, entry := range p.progress[studentID] {
+		if week, err := strconv.Atoi(key); err == nil {
+			result[week] = entry.clone()
+		}
+	}
+	return result
+}
+
+// update applies fn to a copy of the student's progress for a lesson and
+// swaps it in once saved, keeping the previous entry if the save fails
+func (p *progressStore) update(studentID string, lesson *Lesson, fn func(*LessonProgress)) (LessonProgress, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	entries := p.progress[studentID]
+	if entries == nil {
+		entries = make(map[string]*LessonProgress)
+		p.progress[studentID] = entries
+	}
+	key := strconv.Itoa(lesson.Week)
+	previous := entries[key]
+
+	entry := LessonProgress{Week: lesson.Week}
+	if previous != nil {
+		entry = previous.clone()
+	}
+	entry.Section = lesson.SectionID
+	fn(&entry)
+	entry.UpdatedAt = time.Now()
+
+	entries[key] = &entry
+	if err := p.store.save(p.progress); err != nil {
+		if previous != nil {
+			entries[key] = previous
+		} else {
+			delete(entries, key)
+			if len(entries) == 0 {
+				delete(p.progress, studentID)
+			}
+		}
+		return LessonProgress{}, err
+	}
+	return entry.clone(), nil
+}
+
+// addDay returns days with day added in order, never modifying days
+func addDay(days []int, day int) []int {
+	for _, d := range days {
+		if d == day {
+			return days
+		}
+	}
+	result := append(make([]int, 0, len(days)+1), days...)
+	result = append(result, day)
+	sort.Ints(result)
+	return result
+}
+
+// removeDay returns days without day, never modifying days
+func removeDay(days []int, day int) []int {
+	result := make([]int, 0, len(days))
+	for _, d := range days {
+		if d != day {
+			result = append(result, d)
+		}
+	}
+	return result
+}
+
+// summarizeSectionProgress counts lesson- and day-level completion. The
+// percentage uses Day blocks where lessons have them, whole lessons otherwise.
+func summarizeSectionProgress(section *Section, progress map[int]LessonProgress) *SectionProgress {
+	summary := &SectionProgress{LessonsTotal: len(section.Lessons)}
+
+	units, done := 0, 0
+	for _, lesson := range section.Lessons {
+		entry, tracked := progress[lesson.Week]
+		summary.DaysTotal += len(lesson.Days)
+
+		if tracked && entry.OpenedAt != nil {
+			summary.LessonsOpened++
+		}
+		if tracked && entry.CompletedAt != nil {
+			summary.LessonsCompleted++
+		}
+
+		completedDays := 0
+		if tracked {
+			for _, day := range entry.DaysCompleted {
+				for _, d := range lesson.Days {
+					if d.Number == day {
+						completedDays++
+					}
+				}
+			}
+		}
+		summary.DaysCompleted += completedDays
+
+		if len(lesson.Days) > 0 {
+			units += len(lesson.Days)
+			if tracked && entry.CompletedAt != nil {
+				done += len(lesson.Days)
+			} else {
+				done += completedDays
+			}
+		} else {
+			units++
+			if tracked && entry.CompletedAt != nil {
+				done++
+			}
+		}
+	}
+
+	if units > 0 {
+		summary.Percent = float64(int(float64(done)/float64(units)*10000)) / 100
+	}
+	return summary
+}
+
+// requestStudentID identifies the student a request acts for
+func (s *Server) requestStudentID(r *http.Request) string {
+	return s.actingStudent(r, "")
+}
+
+func (s *Server) handleProgress(w http.ResponseWriter, r *http.Request) {
+	studentID := s.requestStudentID(r)
+	if !studentIDPattern.MatchString(studentID) {
+		http.Error(w, "Student not identified", http.StatusUnauthorized)
+		return
+	}
+
+	progress := s.progress.forStudent(studentID)
+
+	s.mutex.RLock()
+	sections := make(map[string]*SectionProgress)
+	for sectionID, section := range s.sections {
+		sections[sectionID] = summarizeSectionProgress(section, progress)
+	}
+	s.mutex.RUnlock()
+
+	lessons := make([]LessonProgress, 0, len(progress))
+	for _, entry := range progress {
+		lessons = append(lessons, entry)
+	}
+	sort.Slice(lessons, func(i, j int) bool {
+		return lessons[i].Week < lessons[j].Week
+	})
+
+	response := struct {
+		StudentID string                      `json:"student_id"`
+		Sections  map[string]*SectionProgress `json:"sections"`
+		Lessons   []LessonProgress            `json:"lessons"`
+	}{
+		StudentID: studentID,
+		Sections:  sections,
+		Lessons:   lessons,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+type progressRequest struct {
+	Action string `json:"action"` // "open", "complete" or "uncomplete"
+	Day    int    `json:"day"`    // Optional Day N block the action applies to
+}
+
+func (s *Server) handleUpdateProgress(w http.ResponseWriter, r *http.Request) {
+	studentID := s.requestStudentID(r)
+	if !studentIDPattern.MatchString(studentID) {
+		http.Error(w, "Student not identified", http.StatusUnauthorized)
+		return
+	}
+
+	lesson, ok := s.lessonForSectionWeek(w, r)
+	if !ok {
+		return
+	}
+
+	var req progressRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Day != 0 {
+		found := false
+		for _, d := range lesson.Days {
+			found = found || d.Number == req.Day
+		}
+		if !found {
+			http.Error(w, "Day not found in lesson", http.StatusBadRequest)
+			return
+		}
+	}
+
+	now := time.Now()
+	var apply func(*LessonProgress)
+	switch req.Action {
+	case "open":
+		apply = func(p *LessonProgress) {
+			if p.OpenedAt == nil {
+				p.OpenedAt = &now
+			}
+			if req.Day != 0 {
+				p.DaysOpened = addDay(p.DaysOpened, req.Day)
+			}
+		}
+	case "complete":
+		apply = func(p *LessonProgress) {
+			if p.OpenedAt == nil {
+				p.OpenedAt = &now
+			}
+			if req.Day != 0 {
+				p.DaysOpened = addDay(p.DaysOpened, req.Day)
+				p.DaysCompleted = addDay(p.DaysCompleted, req.Day)
+				if len(lesson.Days) > 0 && len(p.DaysCompleted) >= len(lesson.Days) && p.CompletedAt == nil {
+					p.CompletedAt = &now
+				}
+			} else if p.CompletedAt == nil {
+				p.CompletedAt = &now
+			}
+		}
+	case "uncomplete":
+		apply = func(p *LessonProgress) {
+			if req.Day != 0 {
+				p.DaysCompleted = removeDay(p.DaysCompleted, req.Day)
+			}
+			p.CompletedAt = nil
+		}
+	default:
+		http.Error(w, `Action must be "open", "complete" or "uncomplete"`, http.StatusBadRequest)
+		return
+	}
+
+	entry, err := s.progress.update(studentID, lesson, apply)
+	if err != nil {
+		slog.Error("failed to save progress", "student", studentID, "week", lesson.Week, "error", err)
+		http.Error(w, "Failed to save progress", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entry)
+}
@@ -0,0 +1,468 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxQuestionTitle = 200
+	maxQuestionBody  = 10000
+)
+
+// Question is a student question attached to a lesson and optionally to one
+// heading anchor within it
+type Question struct {
+	ID               string    `json:"id"`
+	Week             int       `json:"week"`
+	Section          string    `json:"section"`
+	Anchor           string    `json:"anchor,omitempty"`
+	Title            string    `json:"title"`
+	Body             string    `json:"body"`
+	Author           string    `json:"author"`
+	AuthorName       string    `json:"author_name,omitempty"`
+	Hidden           bool      `json:"hidden"`
+	AcceptedAnswerID string    `json:"accepted_answer_id,omitempty"`
+	Answers          []*Answer `json:"answers"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+type Answer struct {
+	ID         string    `json:"id"`
+	Body       string    `json:"body"`
+	Author     string    `json:"author"`
+	AuthorName string    `json:"author_name,omitempty"`
+	Instructor bool      `json:"instructor"`
+	Hidden     bool      `json:"hidden"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// answered reports whether the question needs no further instructor
+// attention: it has an accepted answer or a visible instructor answer
+func (q *Question) answered() bool {
+	if q.AcceptedAnswerID != "" {
+		return true
+	}
+	for _, answer := range q.Answers {
+		if answer.Instructor && !answer.Hidden {
+			return true
+		}
+	}
+	return false
+}
+
+// clone copies the question and its answers so callers can encode it
+// without holding the store lock
+func (q *Question) clone() *Question {
+	copied := *q
+	copied.Answers = make([]*Answer, 0, len(q.Answers))
+	for _, answer := range q.Answers {
+		answerCopy := *answer
+		copied.Answers = append(copied.Answers, &answerCopy)
+	}
+	return &copied
+}
+
+// visibleTo drops hidden answers for non-moderators
+func (q *Question) visibleTo(moderator bool) *Question {
+	if moderator {
+		return q
+	}
+	answers := make([]*Answer, 0, len(q.Answers))
+	for _, answer := range q.Answers {
+		if !answer.Hidden {
+			answers = append(answers, answer)
+		}
+	}
+	q.Answers = answers
+	return q
+}
+
+var (
+	errQuestionNotFound = fmt.Errorf("question not found")
+	errAnswerNotFound   = fmt.Errorf("answer not found")
+)
+
+// qaStore keeps every question and its answers in questions.json
+type qaStore struct {
+	mutex     sync.RWMutex
+	store     *jsonStore
+	questions []*Question
+}
+
+func openQAStore(path string) (*qaStore, error) {
+	q := &qaStore{}
+	store, err := openJSONStore(path, &q.questions)
+	if err != nil {
+		return nil, err
+	}
+	q.store = store
+	return q, nil
+}
+
+// flush writes the store one last time before shutdown
+func (q *qaStore) flush() error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.store.save(q.questions)
+}
+
+func (q *qaStore) find(id string) *Question {
+	for _, question := range q.questions {
+		if question.ID == id {
+			return question
+		}
+	}
+	return nil
+}
+
+func (q *qaStore) add(question *Question) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.questions = append(q.questions, question)
+	if err := q.store.save(q.questions); err != nil {
+		q.questions = q.questions[:len(q.questions)-1]
+		return err
+	}
+	return nil
+}
+
+// update applies fn to a copy of one question and swaps it in once saved.
+// fn returning an error, or a failed save, leaves the question unchanged.
+func (q *qaStore) update(id string, fn func(*Question) error) (*Question, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	index := -1
+	for i, question := range q.questions {
+		if question.ID == id {
+			index = i
+		}
+	}
+	if index < 0 {
+		return nil, errQuestionNotFound
+	}
+
+	previous := q.questions[index]
+	question := previous.clone()
+	if err := fn(question); err != nil {
+		return nil, err
+	}
+	question.UpdatedAt = time.Now()
+
+	q.questions[index] = question
+	if err := q.store.save(q.questions); err != nil {
+		q.questions[index] = previous
+		return nil, err
+	}
+	return question.clone(), nil
+}
+
+func (q *qaStore) get(id string) (*Question, bool) {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	question := q.find(id)
+	if question == nil {
+		return nil, false
+	}
+	return question.clone(), true
+}
+
+func (q *qaStore) remove(id string) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for i, question := range q.questions {
+		if question.ID == id {
+			previous := q.questions
+			q.questions = append(q.questions[:i:i], q.questions[i+1:]...)
+			if err := q.store.save(q.questions); err != nil {
+				q.questions = previous
+				return err
+			}
+			return nil
+		}
+	}
+	return errQuestionNotFound
+}
+
+// list returns questions matching filter, oldest first
+func (q *qaStore) list(filter func(*Question) bool) []*Question {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	result := make([]*Question, 0)
+	for _, question := range q.questions {
+		if filter(question) {
+			result = append(result, question.clone())
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result
+}
+
+func isModerator(r *http.Request) bool {
+	user := requestUser(r)
+	return user != nil && user.Role == roleInstructor
+}
+
+// lessonAnchors lists the heading ids questions may attach to
+func (s *Server) lessonAnchors(lesson *Lesson) map[string]bool {
+	anchors := make(map[string]bool)
+	for _, item := range s.extractTOCFromHeadings(lesson.Content) {
+		anchors[item.ID] = true
+	}
+	return anchors
+}
+
+func (s *Server) handleLessonQuestions(w http.ResponseWriter, r *http.Request) {
+	lesson, ok := s.lessonForSectionWeek(w, r)
+	if !ok {
+		return
+	}
+
+	moderator := isModerator(r)
+	anchor := r.URL.Query().Get("anchor")
+	questions := s.questions.list(func(q *Question) bool {
+		return q.Week == lesson.Week && (anchor == "" || q.Anchor == anchor) && (moderator || !q.Hidden)
+	})
+	for i, question := range questions {
+		questions[i] = question.visibleTo(moderator)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(questions)
+}
+
+type questionRequest struct {
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+	Anchor string `json:"anchor"`
+}
+
+func (s *Server) handleAskQuestion(w http.ResponseWriter, r *http.Request) {
+	lesson, ok := s.lessonForSectionWeek(w, r)
+	if !ok {
+		return
+	}
+
+	var req questionRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	req.Title = strings.TrimSpace(req.Title)
+	req.Body = strings.TrimSpace(req.Body)
+	if req.Title == "" || len(req.Title) > maxQuestionTitle {
+		http.Error(w, fmt.Sprintf("Title is required and limited to %d characters", maxQuestionTitle), http.StatusBadRequest)
+		return
+	}
+	if len(req.Body) > maxQuestionBody {
+		http.Error(w, fmt.Sprintf("Body is limited to %d characters", maxQuestionBody), http.StatusBadRequest)
+		return
+	}
+	if req.Anchor != "" && !s.lessonAnchors(lesson)[req.Anchor] {
+		http.Error(w, "Anchor not found in lesson", http.StatusBadRequest)
+		return
+	}
+
+	user := requestUser(r)
+	now := time.Now()
+	question := &Question{
+		ID:         newRecordID(),
+		Week:       lesson.Week,
+		Section:    lesson.SectionID,
+		Anchor:     req.Anchor,
+		Title:      req.Title,
+		Body:       req.Body,
+		Author:     user.Username,
+		AuthorName: user.Name,
+		Answers:    []*Answer{},
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	if err := s.questions.add(question); err != nil {
+		slog.Error("failed to save question", "id", question.ID, "error", err)
+		http.Error(w, "Failed to save question", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(question.clone())
+}
+
+// writeQuestionError maps store errors onto HTTP responses
+func writeQuestionError(w http.ResponseWriter, err error) {
+	switch err {
+	case errQuestionNotFound:
+		http.Error(w, "Question not found", http.StatusNotFound)
+	case errAnswerNotFound:
+		http.Error(w, "Answer not found", http.StatusNotFound)
+	default:
+		slog.Error("failed to save question", "error", err)
+		http.Error(w, "Failed to save question", http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleAnswerQuestion(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Body string `json:"body"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	req.Body = strings.TrimSpace(req.Body)
+	if req.Body == "" || len(req.Body) > maxQuestionBody {
+		http.Error(w, fmt.Sprintf("Body is required and limited to %d characters", maxQuestionBody), http.StatusBadRequest)
+		return
+	}
+
+	user := requestUser(r)
+	moderator := isModerator(r)
+	answer := &Answer{
+		ID:         newRecordID(),
+		Body:       req.Body,
+		Author:     user.Username,
+		AuthorName: user.Name,
+		Instructor: moderator,
+		CreatedAt:  time.Now(),
+	}
+
+	question, err := s.questions.update(mux.Vars(r)["id"], func(q *Question) error {
+		// Hidden questions are closed to everyone but moderators
+		if q.Hidden && !moderator {
+			return errQuestionNotFound
+		}
+		q.Answers = append(q.Answers, answer)
+		return nil
+	})
+	if err != nil {
+		writeQuestionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(question.visibleTo(moderator))
+}
+
+// handleAcceptAnswer marks one answer as accepted. The question's author or
+// an instructor may accept; an empty answer_id clears the acceptance.
+func (s *Server) handleAcceptAnswer(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		AnswerID string `json:"answer_id"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	existing, exists := s.questions.get(id)
+	if !exists || (existing.Hidden && !isModerator(r)) {
+		http.Error(w, "Question not found", http.StatusNotFound)
+		return
+	}
+	if !isModerator(r) && existing.Author != requestUser(r).Username {
+		http.Error(w, "Only the question's author or an instructor can accept an answer", http.StatusForbidden)
+		return
+	}
+
+	question, err := s.questions.update(id, func(q *Question) error {
+		if req.AnswerID == "" {
+			q.AcceptedAnswerID = ""
+			return nil
+		}
+		for _, answer := range q.Answers {
+			if answer.ID == req.AnswerID && !answer.Hidden {
+				q.AcceptedAnswerID = answer.ID
+				return nil
+			}
+		}
+		return errAnswerNotFound
+	})
+	if err != nil {
+		writeQuestionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(question.visibleTo(isModerator(r)))
+}
+
+type moderationRequest struct {
+	AnswerID string `json:"answer_id"` // Moderates this answer instead of the question
+	Hidden   bool   `json:"hidden"`
+}
+
+// handleModerateQuestion hides or restores a question or one of its answers.
+// Hiding the accepted answer clears the acceptance.
+func (s *Server) handleModerateQuestion(w http.ResponseWriter, r *http.Request) {
+	var req moderationRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	question, err := s.questions.update(mux.Vars(r)["id"], func(q *Question) error {
+		if req.AnswerID == "" {
+			q.Hidden = req.Hidden
+			return nil
+		}
+		for _, answer := range q.Answers {
+			if answer.ID == req.AnswerID {
+				answer.Hidden = req.Hidden
+				if req.Hidden && q.AcceptedAnswerID == answer.ID {
+					q.AcceptedAnswerID = ""
+				}
+				return nil
+			}
+		}
+		return errAnswerNotFound
+	})
+	if err != nil {
+		writeQuestionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(question)
+}
+
+func (s *Server) handleDeleteQuestion(w http.ResponseWriter, r *http.Request) {
+	if err := s.questions.remove(mux.Vars(r)["id"]); err != nil {
+		writeQuestionError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleUnansweredQuestions is the instructor's queue of visible questions
+// with neither an accepted answer nor an instructor reply, oldest first
+func (s *Server) handleUnansweredQuestions(w http.ResponseWriter, r *http.Request) {
+	sectionID := r.URL.Query().Get("section")
+	questions := s.questions.list(func(q *Question) bool {
+		return !q.Hidden && !q.answered() && (sectionID == "" || q.Section == sectionID)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(questions)
+}
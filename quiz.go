@@ -0,0 +1,471 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	questionMultipleChoice = "multiple_choice"
+	questionMultiSelect    = "multi_select"
+	questionTrueFalse      = "true_false"
+	questionShortAnswer    = "short_answer"
+)
+
+// Quiz is declared in a fenced ```quiz block. The answer key stays on the
+// server: lessons only carry a placeholder and clients get a PublicQuiz.
+type Quiz struct {
+	ID          string          `yaml:"id"`
+	Title       string          `yaml:"title"`
+	MaxAttempts int             `yaml:"max_attempts"`
+	Questions   []*QuizQuestion `yaml:"questions"`
+	Week        int             `yaml:"-"`
+	Section     string          `yaml:"-"`
+}
+
+type QuizQuestion struct {
+	ID      string      `yaml:"id"`
+	Type    string      `yaml:"type"`
+	Prompt  string      `yaml:"prompt"`
+	Choices []string    `yaml:"choices"`
+	Answer  interface{} `yaml:"answer"` // Choice index or text, list of them, or bool
+	Accept  []string    `yaml:"accept"` // Regular expressions for short answers
+	Points  float64     `yaml:"points"`
+
+	correctChoices []int
+	correctBool    bool
+	acceptPatterns []*regexp.Regexp
+}
+
+// PublicQuiz is what students see: no answers, no accepted patterns
+type PublicQuiz struct {
+	ID          string           `json:"id"`
+	Title       string           `json:"title"`
+	MaxAttempts int              `json:"max_attempts,omitempty"`
+	Points      float64          `json:"points"`
+	Questions   []PublicQuestion `json:"questions"`
+}
+
+type PublicQuestion struct {
+	ID      string   `json:"id"`
+	Type    string   `json:"type"`
+	Prompt  string   `json:"prompt"`
+	Choices []string `json:"choices,omitempty"`
+	Points  float64  `json:"points"`
+}
+
+var quizBlockPattern = regexp.MustCompile("(?ms)^```quiz[ \\t]*\\r?\\n(.*?)^```[ \\t]*$")
+
+// extractQuizzes parses ```quiz blocks and replaces each with a placeholder
+// element the frontend can mount the quiz into
+func (s *Server) extractQuizzes(lesson *Lesson) ([]*Quiz, []string) {
+	var quizzes []*Quiz
+	var warnings []string
+
+	lesson.Content = quizBlockPattern.ReplaceAllStringFunc(lesson.Content, func(block string) string {
+		body := quizBlockPattern.FindStringSubmatch(block)[1]
+
+		quiz := &Quiz{}
+		if err := yaml.Unmarshal([]byte(body), quiz); err != nil {
+			warnings = append(warnings, fmt.Sprintf("invalid quiz block: %v", err))
+			return `<div class="quiz quiz-error">Quiz unavailable</div>`
+		}
+		if err := s.prepareQuiz(quiz, lesson, len(quizzes)+1); err != nil {
+			warnings = append(warnings, fmt.Sprintf("quiz %q: %v", quiz.ID, err))
+			return `<div class="quiz quiz-error">Quiz unavailable</div>`
+		}
+		for _, other := range quizzes {
+			if other.ID == quiz.ID {
+				warnings = append(warnings, fmt.Sprintf("duplicate quiz id %q", quiz.ID))
+				return `<div class="quiz quiz-error">Quiz unavailable</div>`
+			}
+		}
+
+		quizzes = append(quizzes, quiz)
+		return fmt.Sprintf(`<div class="quiz" data-quiz-id="%s"></div>`, quiz.ID)
+	})
+
+	return quizzes, warnings
+}
+
+// prepareQuiz fills defaults and normalizes every answer key
+func (s *Server) prepareQuiz(quiz *Quiz, lesson *Lesson, index int) error {
+	quiz.Week = lesson.Week
+	quiz.Section = lesson.SectionID
+	// Attempts are keyed by quiz ID across the course, so IDs from
+	// frontmatter are always scoped by week: `id: quiz1` in week 13 is
+	// week13-quiz1
+	prefix := fmt.Sprintf("week%d-", lesson.Week)
+	quiz.ID = s.generateIDFromTitle(quiz.ID)
+	if quiz.ID == "" {
+		quiz.ID = fmt.Sprintf("quiz%d", index)
+	}
+	if !strings.HasPrefix(quiz.ID, prefix) {
+		quiz.ID = prefix + quiz.ID
+	}
+	if quiz.Title == "" {
+		quiz.Title = fmt.Sprintf("Week %d Quiz", lesson.Week)
+	}
+	if len(quiz.Questions) == 0 {
+		return fmt.Errorf("no questions")
+	}
+
+	seen := make(map[string]bool)
+	for i, q := range quiz.Questions {
+		if q.ID == "" {
+			q.ID = fmt.Sprintf("q%d", i+1)
+		}
+		if seen[q.ID] {
+			return fmt.Errorf("duplicate question id %q", q.ID)
+		}
+		seen[q.ID] = true
+		if q.Points <= 0 {
+			q.Points = 1
+		}
+		if err := q.prepare(); err != nil {
+			return fmt.Errorf("question %s: %w", q.ID, err)
+		}
+	}
+	return nil
+}
+
+func (q *QuizQuestion) prepare() error {
+	switch q.Type {
+	case questionMultipleChoice, questionMultiSelect:
+		if len(q.Choices) < 2 {
+			return fmt.Errorf("needs at least two choices")
+		}
+		answers, ok := q.Answer.([]interface{})
+		if !ok {
+			answers = []interface{}{q.Answer}
+		}
+		for _, answer := range answers {
+			index, err := q.choiceIndex(answer)
+			if err != nil {
+				return err
+			}
+			q.correctChoices = append(q.correctChoices, index)
+		}
+		sort.Ints(q.correctChoices)
+		if q.Type == questionMultipleChoice && len(q.correctChoices) != 1 {
+			return fmt.Errorf("multiple_choice needs exactly one answer")
+		}
+	case questionTrueFalse:
+		value, ok := q.Answer.(bool)
+		if !ok {
+			return fmt.Errorf("true_false answer must be true or false")
+		}
+		q.correctBool = value
+		q.Choices = nil
+	case questionShortAnswer:
+		patterns := q.Accept
+		if answer, ok := q.Answer.(string); ok && answer != "" {
+			patterns = append(patterns, regexp.QuoteMeta(answer))
+		}
+		if len(patterns) == 0 {
+			return fmt.Errorf("short_answer needs accept patterns or an answer")
+		}
+		for _, pattern := range patterns {
+			re, err := regexp.Compile(`(?i)^\s*(?:` + pattern + `)\s*$`)
+			if err != nil {
+				return fmt.Errorf("invalid accept pattern %q: %w", pattern, err)
+			}
+			q.acceptPatterns = append(q.acceptPatterns, re)
+		}
+		q.Choices = nil
+	default:
+		return fmt.Errorf("unknown question type %q", q.Type)
+	}
+	return nil
+}
+
+// choiceIndex accepts a 0-based index or the choice text
+func (q *QuizQuestion) choiceIndex(answer interface{}) (int, error) {
+	switch v := answer.(type) {
+	case int:
+		if v >= 0 && v < len(q.Choices) {
+			return v, nil
+		}
+	case string:
+		for i, choice := range q.Choices {
+			if strings.EqualFold(strings.TrimSpace(choice), strings.TrimSpace(v)) {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("answer %v does not match a choice", answer)
+}
+
+// grade reports whether a student's raw JSON answer is correct
+func (q *QuizQuestion) grade(raw json.RawMessage) bool {
+	if len(raw) == 0 {
+		return false
+	}
+	switch q.Type {
+	case questionMultipleChoice:
+		var index int
+		return json.Unmarshal(raw, &index) == nil && index == q.correctChoices[0]
+	case questionMultiSelect:
+		var indexes []int
+		if json.Unmarshal(raw, &indexes) != nil {
+			return false
+		}
+		sort.Ints(indexes)
+		if len(indexes) != len(q.correctChoices) {
+			return false
+		}
+		for i := range indexes {
+			if indexes[i] != q.correctChoices[i] {
+				return false
+			}
+		}
+		return true
+	case questionTrueFalse:
+		var value bool
+		return json.Unmarshal(raw, &value) == nil && value == q.correctBool
+	case questionShortAnswer:
+		var text string
+		if json.Unmarshal(raw, &text) != nil {
+			return false
+		}
+		for _, re := range q.acceptPatterns {
+			if re.MatchString(text) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (quiz *Quiz) public() PublicQuiz {
+	result := PublicQuiz{ID: quiz.ID, Title: quiz.Title, MaxAttempts: quiz.MaxAttempts}
+	for _, q := range quiz.Questions {
+		result.Points += q.Points
+		result.Questions = append(result.Questions, PublicQuestion{
+			ID:      q.ID,
+			Type:    q.Type,
+			Prompt:  q.Prompt,
+			Choices: q.Choices,
+			Points:  q.Points,
+		})
+	}
+	return result
+}
+
+// QuizAttempt is a graded attempt. Results report which questions were
+// right, never what the right answer was.
+type QuizAttempt struct {
+	ID          string                     `json:"id"`
+	QuizID      string                     `json:"quiz_id"`
+	StudentID   string                     `json:"student_id"`
+	Week        int                        `json:"week"`
+	Section     string                     `json:"section"`
+	Answers     map[string]json.RawMessage `json:"answers"`
+	Correct     map[string]bool            `json:"correct"`
+	Score       float64                    `json:"score"`
+	MaxScore    float64                    `json:"max_score"`
+	Attempt     int                        `json:"attempt"`
+	SubmittedAt time.Time                  `json:"submitted_at"`
+}
+
+// quizResultStore keeps every graded attempt in quiz-results.json
+type quizResultStore struct {
+	mutex    sync.RWMutex
+	store    *jsonStore
+	attempts []*QuizAttempt
+}
+
+func openQuizResultStore(path string) (*quizResultStore, error) {
+	q := &quizResultStore{}
+	store, err := openJSONStore(path, &q.attempts)
+	if err != nil {
+		return nil, err
+	}
+	q.store = store
+	return q, nil
+}
+
+// flush writes the store one last time before shutdown
+func (q *quizResultStore) flush() error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.store.save(q.attempts)
+}
+
+// add numbers the attempt, enforcing maxAttempts when it is set
+func (q *quizResultStore) add(attempt *QuizAttempt, maxAttempts int) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	count := 0
+	for _, existing := range q.attempts {
+		if existing.QuizID == attempt.QuizID && existing.StudentID == attempt.StudentID {
+			count++
+		}
+	}
+	if maxAttempts > 0 && count >= maxAttempts {
+		return errMaxAttempts
+	}
+	attempt.Attempt = count + 1
+
+	q.attempts = append(q.attempts, attempt)
+	if err := q.store.save(q.attempts); err != nil {
+		q.attempts = q.attempts[:len(q.attempts)-1]
+		return err
+	}
+	return nil
+}
+
+func (q *quizResultStore) list(quizID, studentID string) []*QuizAttempt {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	result := make([]*QuizAttempt, 0)
+	for _, attempt := range q.attempts {
+		if quizID != "" && attempt.QuizID != quizID {
+			continue
+		}
+		if studentID != "" && attempt.StudentID != studentID {
+			continue
+		}
+		result = append(result, attempt)
+	}
+	return result
+}
+
+var errMaxAttempts = fmt.Errorf("maximum attempts reached")
+
+// lessonForSectionWeek resolves /sections/{section}/week/{week} to a lesson
+func (s *Server) lessonForSectionWeek(w http.ResponseWriter, r *http.Request) (*Lesson, bool) {
+	vars := mux.Vars(r)
+
+	week, err := strconv.Atoi(vars["week"])
+	if err != nil || week < 1 {
+		http.Error(w, "Invalid week number", http.StatusBadRequest)
+		return nil, false
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	section, exists := s.sections[vars["section"]]
+	if !exists {
+		http.Error(w, "Section not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	lesson, exists := s.lessons[section.WeekStart+week-1]
+	if !exists {
+		http.Error(w, "Lesson not found", http.StatusNotFound)
+		return nil, false
+	}
+	return lesson, true
+}
+
+func (s *Server) handleLessonQuizzes(w http.ResponseWriter, r *http.Request) {
+	lesson, ok := s.lessonForSectionWeek(w, r)
+	if !ok {
+		return
+	}
+
+	quizzes := make([]PublicQuiz, 0, len(lesson.Quizzes))
+	for _, quiz := range lesson.Quizzes {
+		quizzes = append(quizzes, quiz.public())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quizzes)
+}
+
+type quizAttemptRequest struct {
+	Student string                     `json:"student"` // Only honored for instructors
+	Answers map[string]json.RawMessage `json:"answers"`
+}
+
+func (s *Server) handleSubmitQuizAttempt(w http.ResponseWriter, r *http.Request) {
+	lesson, ok := s.lessonForSectionWeek(w, r)
+	if !ok {
+		return
+	}
+
+	quizID := mux.Vars(r)["quiz"]
+	var quiz *Quiz
+	for _, candidate := range lesson.Quizzes {
+		if candidate.ID == quizID {
+			quiz = candidate
+		}
+	}
+	if quiz == nil {
+		http.Error(w, "Quiz not found", http.StatusNotFound)
+		return
+	}
+
+	var req quizAttemptRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	studentID := s.actingStudent(r, req.Student)
+	if !studentIDPattern.MatchString(studentID) {
+		http.Error(w, "Invalid or missing student id", http.StatusBadRequest)
+		return
+	}
+
+	attempt := &QuizAttempt{
+		ID:          newRecordID(),
+		QuizID:      quiz.ID,
+		StudentID:   studentID,
+		Week:        quiz.Week,
+		Section:     quiz.Section,
+		Answers:     make(map[string]json.RawMessage),
+		Correct:     make(map[string]bool),
+		SubmittedAt: time.Now(),
+	}
+	for _, q := range quiz.Questions {
+		raw := req.Answers[q.ID]
+		if len(raw) > 0 {
+			attempt.Answers[q.ID] = raw
+		}
+		attempt.MaxScore += q.Points
+		if q.grade(raw) {
+			attempt.Correct[q.ID] = true
+			attempt.Score += q.Points
+		} else {
+			attempt.Correct[q.ID] = false
+		}
+	}
+	attempt.Score = math.Round(attempt.Score*100) / 100
+
+	if err := s.quizResults.add(attempt, quiz.MaxAttempts); err != nil {
+		if err == errMaxAttempts {
+			http.Error(w, "Maximum attempts reached", http.StatusConflict)
+			return
+		}
+		slog.Error("failed to store quiz attempt", "quiz", quiz.ID, "student", studentID, "error", err)
+		http.Error(w, "Failed to store attempt", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(attempt)
+}
+
+func (s *Server) handleQuizAttempts(w http.ResponseWriter, r *http.Request) {
+	attempts := s.quizResults.list(mux.Vars(r)["quiz"], r.URL.Query().Get("student"))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(attempts)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const quizLesson = "---\ntitle: Functions\nweek: 13\n---\n\n# Functions\n\n" +
+	"```quiz\n" +
+	"id: check\n" +
+	"questions:\n" +
+	"  - type: multiple_choice\n" +
+	"    prompt: Which keyword declares a function?\n" +
+	"    choices: [var, function, class]\n" +
+	"    answer: function\n" +
+	"  - type: short_answer\n" +
+	"    prompt: Name the secret value\n" +
+	"    accept: ['zebra[- ]?crossing']\n" +
+	"  - type: true_false\n" +
+	"    prompt: Functions are values\n" +
+	"    answer: true\n" +
+	"```\n"
+
+func TestQuizAnswersStayOnServer(t *testing.T) {
+	ts := newTestServer(t, map[string]string{"section2-javascript/week13.md": quizLesson})
+
+	paths := []string{
+		"/api/sections/section2-javascript/week/1/quiz",
+		"/api/sections/section2-javascript/week/1",
+		"/api/sections/section2-javascript/week/1/content",
+		"/api/lessons/13",
+	}
+	for _, path := range paths {
+		rec := ts.do(nil, http.MethodGet, path, nil, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: status %d", path, rec.Code)
+		}
+		body := rec.Body.String()
+		for _, leak := range []string{"zebra", `"answer"`, `"accept"`} {
+			if strings.Contains(body, leak) {
+				t.Errorf("GET %s leaks %s: %s", path, leak, body)
+			}
+		}
+	}
+
+	rec := ts.do(nil, http.MethodGet, paths[0], nil, nil)
+	var quizzes []PublicQuiz
+	if err := json.NewDecoder(rec.Body).Decode(&quizzes); err != nil {
+		t.Fatal(err)
+	}
+	if len(quizzes) != 1 || len(quizzes[0].Questions) != 3 {
+		t.Fatalf("quizzes = %+v, want one quiz with three questions", quizzes)
+	}
+	if quizzes[0].ID != "week13-check" {
+		t.Errorf("quiz id = %q, want it scoped by week", quizzes[0].ID)
+	}
+	if choices := quizzes[0].Questions[0].Choices; len(choices) != 3 {
+		t.Errorf("multiple choice question lost its choices: %v", choices)
+	}
+}
+
+func TestQuizAttemptIsGradedOnServer(t *testing.T) {
+	ts := newTestServer(t, map[string]string{"section2-javascript/week13.md": quizLesson})
+	client := ts.login("student1")
+
+	body := `{"answers":{"q1":1,"q2":"Zebra crossing","q3":false}}`
+	rec := ts.do(client, http.MethodPost, "/api/sections/section2-javascript/week/1/quiz/week13-check/attempts",
+		strings.NewReader(body), map[string]string{"Content-Type": "application/json"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+
+	var attempt QuizAttempt
+	if err := json.NewDecoder(rec.Body).Decode(&attempt); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"q1": true, "q2": true, "q3": false}
+	for id, correct := range want {
+		if attempt.Correct[id] != correct {
+			t.Errorf("question %s graded %t, want %t", id, attempt.Correct[id], correct)
+		}
+	}
+	if attempt.StudentID != "student1" {
+		t.Errorf("attempt recorded for %q, want student1", attempt.StudentID)
+	}
+}
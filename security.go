@@ -0,0 +1,115 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/handlers"
+)
+
+// defaultContentSecurityPolicy fits the Astro build: hydration scripts and
+// component styles are inlined, and lessons link images, videos and embeds
+// from other sites
+const defaultContentSecurityPolicy = "default-src 'self'; " +
+	"script-src 'self' 'unsafe-inline'; " +
+	"style-src 'self' 'unsafe-inline'; " +
+	"img-src 'self' data: https:; " +
+	"font-src 'self' data:; " +
+	"media-src 'self' https:; " +
+	"frame-src https:; " +
+	"connect-src 'self'; " +
+	"object-src 'none'; " +
+	"base-uri 'self'; " +
+	"form-action 'self'; " +
+	"frame-ancestors 'none'"
+
+// apiContentSecurityPolicy applies to JSON and other non-page responses,
+// which never need to load anything
+const apiContentSecurityPolicy = "default-src 'none'; frame-ancestors 'none'"
+
+// Request headers the front end sends cross-origin, and response headers
+// it needs to read
+var (
+	corsAllowedHeaders = []string{"Content-Type", csrfHeaderName, "If-Match", "If-None-Match",
+		"If-Modified-Since", "Last-Event-ID", "X-Request-ID", studentHeaderName}
+	corsExposedHeaders = []string{"ETag", "Last-Modified", "X-Request-ID", "Content-Disposition"}
+)
+
+// corsCredentials reports whether the configured origins are an explicit
+// allowlist, the only case where cross-origin requests carry the session
+// cookie
+func (s *Server) corsCredentials() bool {
+	for _, origin := range s.corsOrigins {
+		if origin == "*" {
+			return false
+		}
+	}
+	return len(s.corsOrigins) > 0
+}
+
+// corsMiddleware allows the configured origins. Credentials (the session
+// cookie) are only allowed for an explicit allowlist, never for "*".
+func (s *Server) corsMiddleware(next http.Handler) http.Handler {
+	wildcard := !s.corsCredentials()
+
+	options := []handlers.CORSOption{
+		handlers.AllowedOrigins(s.corsOrigins),
+		handlers.AllowedMethods([]string{"GET", "HEAD", "POST", "PUT", "DELETE"}),
+		handlers.AllowedHeaders(corsAllowedHeaders),
+		handlers.ExposedHeaders(corsExposedHeaders),
+		handlers.MaxAge(600),
+	}
+	if wildcard {
+		if len(s.corsOrigins) > 1 {
+			slog.Warn("CORS origins include *, so the other origins are ignored and credentials are disabled",
+				"origins", s.corsOrigins)
+		}
+	} else {
+		options = append(options, handlers.AllowCredentials())
+	}
+	cors := handlers.CORS(options...)(next)
+
+	if wildcard {
+		return cors
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The response depends on Origin even with a single allowed origin
+		w.Header().Add("Vary", "Origin")
+		cors.ServeHTTP(w, r)
+	})
+}
+
+// isPagePath reports whether path serves the front end rather than the API
+// or operational endpoints
+func isPagePath(path string) bool {
+	if path == "/api" || strings.HasPrefix(path, "/api/") {
+		return false
+	}
+	switch path {
+	case "/metrics", "/healthz", "/readyz":
+		return false
+	}
+	return true
+}
+
+// securityHeaders sets browser hardening headers on every response
+func (s *Server) securityHeaders(next http.Handler) http.Handler {
+	pagePolicy := s.contentSecurityPolicy
+	if pagePolicy == "" {
+		pagePolicy = defaultContentSecurityPolicy
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+		header.Set("X-Frame-Options", "DENY")
+		if isPagePath(r.URL.Path) {
+			header.Set("Content-Security-Policy", pagePolicy)
+		} else {
+			header.Set("Content-Security-Policy", apiContentSecurityPolicy)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
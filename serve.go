@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const (
+	readHeaderTimeout = 10 * time.Second
+	// Long enough for a submission upload on a slow connection
+	readTimeout    = 2 * time.Minute
+	writeTimeout   = time.Minute
+	idleTimeout    = 2 * time.Minute
+	maxHeaderBytes = 1 << 20
+
+	// How long in-flight requests get to finish after SIGINT/SIGTERM
+	shutdownTimeout = 15 * time.Second
+)
+
+// serve runs the HTTP server until SIGINT or SIGTERM, then drains in-flight
+// requests, ends event streams, stops the watcher and flushes the stores.
+// With certs it serves HTTPS, and redirectAddr adds a plain HTTP listener
+// that redirects there.
+func (s *Server) serve(addr string, handler http.Handler, certs *certReloader, redirectAddr string) error {
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+		MaxHeaderBytes:    maxHeaderBytes,
+	}
+
+	if certs != nil {
+		httpServer.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certs.getCertificate,
+		}
+		defer certs.close()
+	}
+
+	var redirectServer *http.Server
+	if redirectAddr != "" {
+		redirectServer = &http.Server{
+			Addr:              redirectAddr,
+			Handler:           s.redirectToHTTPS(addr),
+			ReadHeaderTimeout: readHeaderTimeout,
+			ReadTimeout:       readHeaderTimeout,
+			WriteTimeout:      readHeaderTimeout,
+			IdleTimeout:       idleTimeout,
+			MaxHeaderBytes:    maxHeaderBytes,
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if certs != nil {
+			serveErr <- httpServer.ListenAndServeTLS("", "")
+		} else {
+			serveErr <- httpServer.ListenAndServe()
+		}
+	}()
+	redirectErr := make(chan error, 1)
+	if redirectServer != nil {
+		slog.Info("redirecting plain HTTP to HTTPS", "listen", redirectAddr)
+		go func() {
+			redirectErr <- redirectServer.ListenAndServe()
+		}()
+	}
+
+	select {
+	case err := <-serveErr:
+		if redirectServer != nil {
+			redirectServer.Close()
+		}
+		s.stopAfterFailure()
+		return fmt.Errorf("server failed: %w", err)
+	case err := <-redirectErr:
+		httpServer.Close()
+		s.stopAfterFailure()
+		return fmt.Errorf("redirect server failed: %w", err)
+	case <-ctx.Done():
+	}
+	stop()
+	slog.Info("shutting down", "timeout", shutdownTimeout)
+
+	// Shutdown waits for handlers, and event streams never finish on their
+	// own, so end them first
+	s.events.close()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if redirectServer != nil {
+		redirectServer.Shutdown(shutdownCtx)
+	}
+	shutdownErr := httpServer.Shutdown(shutdownCtx)
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("server stopped with error", "error", err)
+	}
+
+	if err := s.close(); err != nil {
+		slog.Warn("failed to stop file watcher", "error", err)
+	}
+	if err := s.flushStores(); err != nil {
+		slog.Error("failed to flush stores", "error", err)
+		return err
+	}
+	if shutdownErr != nil {
+		return fmt.Errorf("requests still running after %v: %w", shutdownTimeout, shutdownErr)
+	}
+
+	slog.Info("shutdown complete")
+	return nil
+}
+
+// stopAfterFailure releases the watcher and persists the stores when a
+// listener fails, so writes accepted before the failure are not lost
+func (s *Server) stopAfterFailure() {
+	s.events.close()
+	if err := s.close(); err != nil {
+		slog.Warn("failed to stop file watcher", "error", err)
+	}
+	if err := s.flushStores(); err != nil {
+		slog.Error("failed to flush stores", "error", err)
+	}
+}
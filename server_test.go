@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testPassword = "correct-horse-battery"
+
+// testServer is a Server with its stores in a temporary directory and the
+// full route stack in front of it
+type testServer struct {
+	*Server
+	handler http.Handler
+	t       *testing.T
+}
+
+// testClient carries a signed-in user's session cookie and CSRF token
+type testClient struct {
+	cookie *http.Cookie
+	csrf   string
+}
+
+// newTestServer writes lessons (paths relative to the lessons directory)
+// and opens a server over them with an "instructor" and a "student1"
+// account, both using testPassword
+func newTestServer(t *testing.T, lessons map[string]string) *testServer {
+	t.Helper()
+
+	root := t.TempDir()
+	lessonsDir := filepath.Join(root, "lessons")
+	for name, content := range lessons {
+		path := filepath.Join(lessonsDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(lessonsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newIndexServer(lessonsDir)
+	s.dataDir = filepath.Join(root, "data")
+	if err := s.scanLessons(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.openStores(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.accounts.setPassword("instructor", testPassword); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.accounts.createUser("student1", "Student One", roleStudent, testPassword); err != nil {
+		t.Fatal(err)
+	}
+
+	return &testServer{Server: s, handler: s.setupRoutes(), t: t}
+}
+
+// login signs username in through the API
+func (ts *testServer) login(username string) *testClient {
+	ts.t.Helper()
+
+	body := `{"username":"` + username + `","password":"` + testPassword + `"}`
+	rec := ts.do(nil, http.MethodPost, "/api/auth/login", strings.NewReader(body), nil)
+	if rec.Code != http.StatusOK {
+		ts.t.Fatalf("login %s: status %d: %s", username, rec.Code, rec.Body)
+	}
+
+	var response struct {
+		CSRFToken string `json:"csrf_token"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		ts.t.Fatal(err)
+	}
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == sessionCookieName {
+			return &testClient{cookie: cookie, csrf: response.CSRFToken}
+		}
+	}
+	ts.t.Fatalf("login %s: no session cookie", username)
+	return nil
+}
+
+// do sends a request as client (nil for anonymous), adding the CSRF token
+// unless headers already set one
+func (ts *testServer) do(client *testClient, method, target string, body io.Reader, headers map[string]string) *httptest.ResponseRecorder {
+	ts.t.Helper()
+
+	req := httptest.NewRequest(method, target, body)
+	if client != nil {
+		req.AddCookie(client.cookie)
+		req.Header.Set(csrfHeaderName, client.csrf)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	rec := httptest.NewRecorder()
+	ts.handler.ServeHTTP(rec, req)
+	return rec
+}
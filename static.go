@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	staticRoot = "lessons/frontend/dist"
+
+	// Astro puts content-hashed bundles under _astro/, so their URLs change
+	// whenever their contents do
+	hashedAssetPrefix = "_astro/"
+	immutableCache    = "public, max-age=31536000, immutable"
+)
+
+// extraMimeTypes covers front-end asset types that the built-in table and a
+// minimal system mime.types may not know
+var extraMimeTypes = map[string]string{
+	".woff":        "font/woff",
+	".woff2":       "font/woff2",
+	".ttf":         "font/ttf",
+	".otf":         "font/otf",
+	".ico":         "image/x-icon",
+	".mp4":         "video/mp4",
+	".webm":        "video/webm",
+	".webmanifest": "application/manifest+json",
+	".map":         "application/json",
+	".txt":         "text/plain; charset=utf-8",
+}
+
+func init() {
+	for ext, mediaType := range extraMimeTypes {
+		if mime.TypeByExtension(ext) == "" {
+			mime.AddExtensionType(ext, mediaType)
+		}
+	}
+}
+
+// staticAsset is one embedded file with its ETag and any precompressed
+// variants
+type staticAsset struct {
+	data   []byte
+	etag   string
+	gzip   []byte
+	brotli []byte
+}
+
+// staticAssets maps paths relative to the front end root to assets. It is
+// filled once by loadStaticAssets before the server starts.
+var staticAssets = make(map[string]*staticAsset)
+
+var precompressExtensions = map[string]bool{
+	".html": true, ".css": true, ".js": true, ".mjs": true, ".json": true,
+	".svg": true, ".xml": true, ".txt": true, ".map": true, ".webmanifest": true,
+}
+
+// precompress returns gzip and brotli copies of data at the highest levels,
+// keeping a variant only when it saves at least 10%
+func precompress(data []byte) (gzipped, brotlied []byte) {
+	var buf bytes.Buffer
+
+	gz, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	gz.Write(data)
+	gz.Close()
+	if buf.Len() < len(data)*9/10 {
+		gzipped = append([]byte(nil), buf.Bytes()...)
+	}
+
+	buf.Reset()
+	br := brotli.NewWriterLevel(&buf, brotli.BestCompression)
+	br.Write(data)
+	br.Close()
+	if buf.Len() < len(data)*9/10 {
+		brotlied = append([]byte(nil), buf.Bytes()...)
+	}
+	return gzipped, brotlied
+}
+
+// variant returns the copy of the asset to send for an Accept-Encoding
+// header and its Content-Encoding, "" for the original bytes. Only variants
+// that were kept are offered, so a client is never sent an encoding it did
+// not accept.
+func (a *staticAsset) variant(acceptEncoding string) ([]byte, string) {
+	var available []string
+	if a.brotli != nil {
+		available = append(available, "br")
+	}
+	if a.gzip != nil {
+		available = append(available, "gzip")
+	}
+
+	switch negotiateEncoding(acceptEncoding, available...) {
+	case "br":
+		return a.brotli, "br"
+	case "gzip":
+		return a.gzip, "gzip"
+	}
+	return a.data, ""
+}
+
+// loadStaticAssets reads the front end once, hashing every file and
+// precompressing the compressible ones. frontend is a directory holding a
+// built front end; empty uses the embedded build.
+func loadStaticAssets(frontend string) error {
+	start := time.Now()
+	var original, compressed, precompressed int
+
+	var root fs.FS
+	if frontend == "" {
+		sub, err := fs.Sub(staticFiles, staticRoot)
+		if err != nil {
+			return fmt.Errorf("failed to open embedded frontend: %w", err)
+		}
+		root = sub
+	} else {
+		if info, err := os.Stat(frontend); err != nil || !info.IsDir() {
+			return fmt.Errorf("frontend %s is not a directory", frontend)
+		}
+		root = os.DirFS(frontend)
+	}
+
+	err := fs.WalkDir(root, ".", func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		data, err := fs.ReadFile(root, filePath)
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(data)
+		asset := &staticAsset{data: data, etag: hex.EncodeToString(sum[:16])}
+		if len(data) >= minCompressSize && precompressExtensions[strings.ToLower(path.Ext(filePath))] {
+			asset.gzip, asset.brotli = precompress(data)
+			if asset.gzip != nil || asset.brotli != nil {
+				precompressed++
+				original += len(data)
+				compressed += len(asset.gzip) + len(asset.brotli)
+			}
+		}
+		staticAssets[filePath] = asset
+		slog.Debug("static file", "path", filePath, "bytes", len(data))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load static files: %w", err)
+	}
+
+	source := frontend
+	if source == "" {
+		source = "embedded"
+	}
+	slog.Info("static files loaded", "source", source, "files", len(staticAssets), "precompressed", precompressed,
+		"original_bytes", original, "compressed_bytes", compressed, "duration", time.Since(start))
+	return nil
+}
+
+// resolveStatic maps a request path to an asset. Paths with a file extension
+// are assets and 404 when missing; anything else falls back to a directory
+// index and then the root index.html for client-side routing.
+func resolveStatic(urlPath string) (string, *staticAsset) {
+	name := strings.TrimPrefix(path.Clean("/"+urlPath), "/")
+	if name == "" {
+		name = "index.html"
+	}
+
+	if asset, ok := staticAssets[name]; ok {
+		return name, asset
+	}
+	if asset, ok := staticAssets[name+"/index.html"]; ok {
+		return name + "/index.html", asset
+	}
+	if path.Ext(name) != "" || strings.HasPrefix(name, hashedAssetPrefix) {
+		return "", nil
+	}
+	if asset, ok := staticAssets["index.html"]; ok {
+		return "index.html", asset
+	}
+	return "", nil
+}
+
+// handleStatic serves the embedded front end with registry MIME types,
+// conditional requests, HEAD and Range, choosing a precompressed variant
+// when the client accepts one
+func (s *Server) handleStatic(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name, asset := resolveStatic(strings.TrimPrefix(r.URL.Path, "/static/"))
+	if asset == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	header := w.Header()
+	if strings.HasPrefix(name, hashedAssetPrefix) {
+		header.Set("Cache-Control", immutableCache)
+	} else {
+		header.Set("Cache-Control", "no-cache")
+	}
+
+	data, etag := asset.data, asset.etag
+	if asset.gzip != nil || asset.brotli != nil {
+		header.Add("Vary", "Accept-Encoding")
+		var encoding string
+		data, encoding = asset.variant(r.Header.Get("Accept-Encoding"))
+		switch encoding {
+		case "br":
+			header.Set("Content-Encoding", "br")
+			etag += "-br"
+		case "gzip":
+			header.Set("Content-Encoding", "gzip")
+			etag += "-gz"
+		}
+	}
+	header.Set("ETag", `"`+etag+`"`)
+
+	// ServeContent takes the Content-Type from the name's extension and
+	// handles If-None-Match, If-Range, Range and HEAD
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(data))
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// useStaticAssets loads files (paths relative to the front end root) in
+// place of the embedded build for the duration of the test
+func useStaticAssets(t *testing.T, files map[string]string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	previous := staticAssets
+	staticAssets = make(map[string]*staticAsset)
+	t.Cleanup(func() { staticAssets = previous })
+	if err := loadStaticAssets(dir); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func serveStatic(method, target string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	rec := httptest.NewRecorder()
+	newIndexServer("").handleStatic(rec, req)
+	return rec
+}
+
+func TestStaticEncodings(t *testing.T) {
+	script := strings.Repeat("console.log('lesson');\n", 200)
+	useStaticAssets(t, map[string]string{
+		"index.html":     "<!doctype html><title>Course</title>",
+		"_astro/app.js":  script,
+		"_astro/only.js": script,
+	})
+	// A variant is dropped when it does not save enough, so model an asset
+	// that only kept gzip
+	staticAssets["_astro/only.js"].brotli = nil
+
+	tests := []struct {
+		name     string
+		path     string
+		accept   string
+		encoding string
+		suffix   string
+	}{
+		{"brotli", "/_astro/app.js", "gzip, br", "br", "-br"},
+		{"gzip", "/_astro/app.js", "gzip", "gzip", "-gz"},
+		{"identity", "/_astro/app.js", "", "", ""},
+		{"gzip refused", "/_astro/app.js", "br;q=0, gzip;q=0", "", ""},
+		{"br only without a brotli variant", "/_astro/only.js", "br", "", ""},
+		{"wildcard without a brotli variant", "/_astro/only.js", "*", "gzip", "-gz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveStatic(http.MethodGet, tt.path, map[string]string{"Accept-Encoding": tt.accept})
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d", rec.Code)
+			}
+			if got := rec.Header().Get("Content-Encoding"); got != tt.encoding {
+				t.Errorf("Content-Encoding = %q, want %q", got, tt.encoding)
+			}
+			if etag := rec.Header().Get("ETag"); !strings.HasSuffix(etag, tt.suffix+`"`) {
+				t.Errorf("ETag = %q, want suffix %q", etag, tt.suffix)
+			}
+			if tt.encoding == "" && rec.Body.String() != script {
+				t.Error("identity body differs from the file")
+			}
+			if !strings.Contains(rec.Header().Get("Vary"), "Accept-Encoding") {
+				t.Error("missing Vary: Accept-Encoding")
+			}
+		})
+	}
+}
+
+func TestStaticRequests(t *testing.T) {
+	script := strings.Repeat("console.log('lesson');\n", 200)
+	useStaticAssets(t, map[string]string{
+		"index.html":       "<!doctype html><title>Course</title>",
+		"about/index.html": "<!doctype html><title>About</title>",
+		"_astro/app.js":    script,
+		"logo.png":         "\x89PNG\r\n\x1a\n",
+	})
+
+	t.Run("range", func(t *testing.T) {
+		rec := serveStatic(http.MethodGet, "/_astro/app.js", map[string]string{"Range": "bytes=0-9"})
+		if rec.Code != http.StatusPartialContent {
+			t.Fatalf("status %d, want %d", rec.Code, http.StatusPartialContent)
+		}
+		if got := rec.Body.String(); got != script[:10] {
+			t.Errorf("body = %q, want %q", got, script[:10])
+		}
+		if got := rec.Header().Get("Content-Range"); got != "bytes 0-9/"+strconv.Itoa(len(script)) {
+			t.Errorf("Content-Range = %q", got)
+		}
+	})
+
+	t.Run("unsatisfiable range", func(t *testing.T) {
+		rec := serveStatic(http.MethodGet, "/logo.png", map[string]string{"Range": "bytes=1000-"})
+		if rec.Code != http.StatusRequestedRangeNotSatisfiable {
+			t.Errorf("status %d, want %d", rec.Code, http.StatusRequestedRangeNotSatisfiable)
+		}
+	})
+
+	t.Run("if-none-match", func(t *testing.T) {
+		etag := serveStatic(http.MethodGet, "/logo.png", nil).Header().Get("ETag")
+		rec := serveStatic(http.MethodGet, "/logo.png", map[string]string{"If-None-Match": etag})
+		if rec.Code != http.StatusNotModified {
+			t.Errorf("status %d, want %d", rec.Code, http.StatusNotModified)
+		}
+	})
+
+	t.Run("head", func(t *testing.T) {
+		rec := serveStatic(http.MethodHead, "/_astro/app.js", nil)
+		if rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+			t.Errorf("status %d with %d body bytes, want 200 and none", rec.Code, rec.Body.Len())
+		}
+		if got := rec.Header().Get("Content-Length"); got != strconv.Itoa(len(script)) {
+			t.Errorf("Content-Length = %q, want %d", got, len(script))
+		}
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		rec := serveStatic(http.MethodPost, "/index.html", nil)
+		if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "GET, HEAD" {
+			t.Errorf("status %d, Allow %q", rec.Code, rec.Header().Get("Allow"))
+		}
+	})
+
+	paths := []struct {
+		path         string
+		status       int
+		body         string
+		cacheControl string
+	}{
+		{"/", http.StatusOK, "<title>Course</title>", "no-cache"},
+		{"/about", http.StatusOK, "<title>About</title>", "no-cache"},
+		{"/lessons/week/3", http.StatusOK, "<title>Course</title>", "no-cache"},
+		{"/_astro/app.js", http.StatusOK, "console.log", immutableCache},
+		{"/_astro/missing", http.StatusNotFound, "", ""},
+		{"/missing.css", http.StatusNotFound, "", ""},
+		{"/../../etc/hosts.txt", http.StatusNotFound, "", ""},
+	}
+	for _, tt := range paths {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := serveStatic(http.MethodGet, tt.path, nil)
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d", rec.Code, tt.status)
+			}
+			if !strings.Contains(rec.Body.String(), tt.body) {
+				t.Errorf("body %q does not contain %q", rec.Body, tt.body)
+			}
+			if got := rec.Header().Get("Cache-Control"); tt.cacheControl != "" && got != tt.cacheControl {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.cacheControl)
+			}
+		})
+	}
+}
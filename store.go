@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// jsonStore persists one value as a JSON file under the data directory.
+// Writes go to a temp file first and are renamed into place so a crash
+// never leaves a half-written store behind.
+type jsonStore struct {
+	path  string
+	mutex sync.Mutex
+}
+
+// openJSONStore loads path into v if it exists and returns the store
+func openJSONStore(path string, v interface{}) (*jsonStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read store %s: %w", path, err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, v); err != nil {
+			return nil, fmt.Errorf("failed to parse store %s: %w", path, err)
+		}
+	}
+
+	return &jsonStore{path: path}, nil
+}
+
+func (st *jsonStore) save(v interface{}) error {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode store %s: %w", st.path, err)
+	}
+	return writeFileAtomic(st.path, data, 0644)
+}
+
+// writeFileAtomic writes data to a temp file in the same directory and
+// renames it over path
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpName := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return fmt.Errorf("failed to sync %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("failed to close %s: %w", path, err)
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("failed to chmod %s: %w", path, err)
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
+
+// openStores opens the local data stores under s.dataDir
+func (s *Server) openStores() error {
+	if err := os.MkdirAll(s.dataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	submissions, err := openSubmissionStore(filepath.Join(s.dataDir, "submissions"))
+	if err != nil {
+		return fmt.Errorf("failed to open submission store: %w", err)
+	}
+	s.submissions = submissions
+
+	gradebook, err := openGradebookStore(filepath.Join(s.dataDir, "gradebook.json"))
+	if err != nil {
+		return fmt.Errorf("failed to open gradebook: %w", err)
+	}
+	s.gradebook = gradebook
+
+	quizResults, err := openQuizResultStore(filepath.Join(s.dataDir, "quiz-results.json"))
+	if err != nil {
+		return fmt.Errorf("failed to open quiz results: %w", err)
+	}
+	s.quizResults = quizResults
+
+	progress, err := openProgressStore(filepath.Join(s.dataDir, "progress.json"))
+	if err != nil {
+		return fmt.Errorf("failed to open progress store: %w", err)
+	}
+	s.progress = progress
+
+	questions, err := openQAStore(filepath.Join(s.dataDir, "questions.json"))
+	if err != nil {
+		return fmt.Errorf("failed to open Q&A store: %w", err)
+	}
+	s.questions = questions
+
+	attendance, err := openAttendanceStore(filepath.Join(s.dataDir, "attendance.json"))
+	if err != nil {
+		return fmt.Errorf("failed to open attendance store: %w", err)
+	}
+	s.attendance = attendance
+
+	accounts, err := openAccountStore(s.dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to open account store: %w", err)
+	}
+	s.accounts = accounts
+	if err := accounts.bootstrapInstructor(s.dataDir); err != nil {
+		return fmt.Errorf("failed to create instructor account: %w", err)
+	}
+
+	return nil
+}
+
+// flushStores saves every store, waiting for any write in progress. The
+// account store opens last, so it being set means all of them are.
+func (s *Server) flushStores() error {
+	if s.accounts == nil {
+		return nil
+	}
+
+	stores := []interface{ flush() error }{
+		s.submissions, s.gradebook, s.quizResults, s.progress, s.questions, s.attendance, s.accounts,
+	}
+	var firstErr error
+	for _, store := range stores {
+		if err := store.flush(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
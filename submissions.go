@@ -0,0 +1,352 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxSubmissionSize = 50 << 20 // 50 MB per upload
+	maxSubmissionText = 1 << 20  // 1 MB for text and link submissions
+)
+
+// Allowed file extensions per assignment submission_type
+var submissionExtensions = map[string][]string{
+	"zip": {".zip"},
+	"file": {
+		".zip", ".html", ".htm", ".css", ".js", ".jsx", ".ts", ".json", ".md", ".txt",
+		".pdf", ".doc", ".docx", ".png", ".jpg", ".jpeg", ".gif", ".svg",
+	},
+}
+
+var (
+	studentIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+	unsafeFileChars  = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+type Submission struct {
+	ID           string     `json:"id"`
+	AssignmentID string     `json:"assignment_id"`
+	StudentID    string     `json:"student_id"`
+	FileName     string     `json:"file_name"`
+	ContentType  string     `json:"content_type"`
+	Size         int64      `json:"size"`
+	SHA256       string     `json:"sha256"`
+	SubmittedAt  time.Time  `json:"submitted_at"`
+	DueAt        *time.Time `json:"due_at,omitempty"`
+	Late         bool       `json:"late"`
+	LateBy       string     `json:"late_by,omitempty"`
+	StoredPath   string     `json:"stored_path"` // Relative to the submissions directory
+}
+
+// submissionStore keeps submitted files under dir/<assignment>/<student>/
+// and their metadata in dir/index.json
+type submissionStore struct {
+	dir   string
+	mutex sync.RWMutex
+	store *jsonStore
+	items []*Submission
+}
+
+func openSubmissionStore(dir string) (*submissionStore, error) {
+	st := &submissionStore{dir: dir}
+	store, err := openJSONStore(filepath.Join(dir, "index.json"), &st.items)
+	if err != nil {
+		return nil, err
+	}
+	st.store = store
+	return st, nil
+}
+
+// flush writes the store one last time before shutdown
+func (st *submissionStore) flush() error {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	return st.store.save(st.items)
+}
+
+func (st *submissionStore) add(sub *Submission) error {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	st.items = append(st.items, sub)
+	if err := st.store.save(st.items); err != nil {
+		st.items = st.items[:len(st.items)-1]
+		return err
+	}
+	return nil
+}
+
+func (st *submissionStore) get(id string) (*Submission, bool) {
+	st.mutex.RLock()
+	defer st.mutex.RUnlock()
+
+	for _, sub := range st.items {
+		if sub.ID == id {
+			return sub, true
+		}
+	}
+	return nil, false
+}
+
+// list returns submissions for an assignment, optionally for one student,
+// newest first
+func (st *submissionStore) list(assignmentID, studentID string) []*Submission {
+	st.mutex.RLock()
+	defer st.mutex.RUnlock()
+
+	result := make([]*Submission, 0)
+	for _, sub := range st.items {
+		if assignmentID != "" && sub.AssignmentID != assignmentID {
+			continue
+		}
+		if studentID != "" && sub.StudentID != studentID {
+			continue
+		}
+		result = append(result, sub)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].SubmittedAt.After(result[j].SubmittedAt)
+	})
+	return result
+}
+
+func newRecordID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return time.Now().UTC().Format("20060102T150405") + "-" + hex.EncodeToString(b)
+}
+
+func sanitizeFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Trim(unsafeFileChars.ReplaceAllString(name, "_"), "._")
+	if name == "" {
+		name = "submission"
+	}
+	return name
+}
+
+func extensionAllowed(submissionType, name string) bool {
+	allowed, exists := submissionExtensions[submissionType]
+	if !exists {
+		allowed = submissionExtensions["file"]
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, candidate := range allowed {
+		if ext == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+// saveSubmissionFile copies the upload to target, returning its size,
+// checksum and sniffed content type
+func saveSubmissionFile(src io.Reader, target string) (int64, string, string, error) {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return 0, "", "", fmt.Errorf("failed to create submission directory: %w", err)
+	}
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, "", "", fmt.Errorf("failed to create submission file: %w", err)
+	}
+	defer out.Close()
+
+	hash := sha256.New()
+	sniff := make([]byte, 512)
+	n, _ := io.ReadFull(src, sniff)
+	sniff = sniff[:n]
+	contentType := http.DetectContentType(sniff)
+
+	size, err := io.Copy(io.MultiWriter(out, hash), io.MultiReader(bytes.NewReader(sniff), src))
+	if err != nil {
+		os.Remove(target)
+		return 0, "", "", fmt.Errorf("failed to store submission: %w", err)
+	}
+
+	return size, hex.EncodeToString(hash.Sum(nil)), contentType, nil
+}
+
+// openSubmissionUpload returns the uploaded file or a text body depending
+// on the assignment's submission type
+func openSubmissionUpload(r *http.Request, a *Assignment) (io.Reader, string, func(), error) {
+	file, header, err := r.FormFile("file")
+	if err == nil {
+		if !extensionAllowed(a.SubmissionType, header.Filename) {
+			file.Close()
+			return nil, "", nil, fmt.Errorf("file type %q not accepted for %s submissions", filepath.Ext(header.Filename), a.SubmissionType)
+		}
+		return file, header.Filename, func() { file.Close() }, nil
+	}
+	if !errors.Is(err, http.ErrMissingFile) {
+		return nil, "", nil, fmt.Errorf("invalid upload: %w", err)
+	}
+
+	text := r.FormValue("text")
+	if text == "" {
+		return nil, "", nil, fmt.Errorf("missing file or text field")
+	}
+	if a.SubmissionType != "text" && a.SubmissionType != "link" {
+		return nil, "", nil, fmt.Errorf("assignment expects a %s upload", a.SubmissionType)
+	}
+	if len(text) > maxSubmissionText {
+		return nil, "", nil, fmt.Errorf("text submission too large")
+	}
+	return strings.NewReader(text), a.SubmissionType + ".txt", func() {}, nil
+}
+
+func (s *Server) handleSubmitAssignment(w http.ResponseWriter, r *http.Request) {
+	assignmentID := mux.Vars(r)["id"]
+
+	s.mutex.RLock()
+	assignment, exists := s.assignments[assignmentID]
+	s.mutex.RUnlock()
+
+	if !exists {
+		http.Error(w, "Assignment not found", http.StatusNotFound)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxSubmissionSize+(1<<20))
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("Submission exceeds %d MB limit", maxSubmissionSize>>20), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid multipart form", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	studentID := s.submittingStudent(r)
+	if !studentIDPattern.MatchString(studentID) {
+		http.Error(w, "Invalid or missing student id", http.StatusBadRequest)
+		return
+	}
+
+	src, fileName, closeSrc, err := openSubmissionUpload(r, assignment)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer closeSrc()
+
+	now := time.Now()
+	sub := &Submission{
+		ID:           newRecordID(),
+		AssignmentID: assignment.ID,
+		StudentID:    studentID,
+		FileName:     sanitizeFileName(fileName),
+		SubmittedAt:  now,
+		DueAt:        assignment.DueAt,
+	}
+	sub.StoredPath = filepath.ToSlash(filepath.Join(assignment.ID, studentID, sub.ID+"-"+sub.FileName))
+	target := filepath.Join(s.submissions.dir, filepath.FromSlash(sub.StoredPath))
+
+	size, sum, contentType, err := saveSubmissionFile(src, target)
+	if err != nil {
+		slog.Error("failed to save submission file", "assignment", assignment.ID, "student", studentID, "error", err)
+		http.Error(w, "Failed to store submission", http.StatusInternalServerError)
+		return
+	}
+	sub.Size, sub.SHA256, sub.ContentType = size, sum, contentType
+
+	if size > maxSubmissionSize {
+		os.Remove(target)
+		http.Error(w, fmt.Sprintf("Submission exceeds %d MB limit", maxSubmissionSize>>20), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if strings.EqualFold(filepath.Ext(sub.FileName), ".zip") {
+		if zr, err := zip.OpenReader(target); err != nil {
+			os.Remove(target)
+			http.Error(w, "Uploaded file is not a valid zip archive", http.StatusBadRequest)
+			return
+		} else {
+			zr.Close()
+		}
+	}
+
+	if sub.DueAt != nil && now.After(*sub.DueAt) {
+		sub.Late = true
+		sub.LateBy = now.Sub(*sub.DueAt).Round(time.Minute).String()
+	}
+
+	if err := s.submissions.add(sub); err != nil {
+		os.Remove(target)
+		slog.Error("failed to record submission", "id", sub.ID, "error", err)
+		http.Error(w, "Failed to record submission", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("submission stored", "id", sub.ID, "assignment", sub.AssignmentID, "student", sub.StudentID, "bytes", sub.Size, "late", sub.Late)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(sub)
+}
+
+// submittingStudent identifies who is submitting
+func (s *Server) submittingStudent(r *http.Request) string {
+	return s.actingStudent(r, r.FormValue("student"))
+}
+
+func (s *Server) handleAssignmentSubmissions(w http.ResponseWriter, r *http.Request) {
+	assignmentID := mux.Vars(r)["id"]
+
+	s.mutex.RLock()
+	_, exists := s.assignments[assignmentID]
+	s.mutex.RUnlock()
+
+	if !exists {
+		http.Error(w, "Assignment not found", http.StatusNotFound)
+		return
+	}
+
+	submissions := s.submissions.list(assignmentID, r.URL.Query().Get("student"))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(submissions)
+}
+
+func (s *Server) handleDownloadSubmission(w http.ResponseWriter, r *http.Request) {
+	sub, exists := s.submissions.get(mux.Vars(r)["id"])
+	if !exists {
+		http.Error(w, "Submission not found", http.StatusNotFound)
+		return
+	}
+
+	path := filepath.Join(s.submissions.dir, filepath.FromSlash(sub.StoredPath))
+	file, err := os.Open(path)
+	if err != nil {
+		slog.Error("failed to open submission", "id", sub.ID, "error", err)
+		http.Error(w, "Submission file missing", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", sub.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s"`, sub.StudentID, sub.FileName))
+	w.Header().Set("X-Checksum-SHA256", sub.SHA256)
+	http.ServeContent(w, r, sub.FileName, sub.SubmittedAt, file)
+}
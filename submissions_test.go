@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const submissionLesson = "---\ntitle: Pages\nweek: 2\nassignments:\n" +
+	"  - id: ../../escape\n    title: Escape attempt\n    points: 10\n" +
+	"  - id: essay\n    title: Essay\n    points: 10\n    submission_type: text\n" +
+	"---\n\nBody\n"
+
+// multipartBody builds an upload with a file field and extra form fields
+func multipartBody(t *testing.T, fileName string, content io.Reader, fields map[string]string) (io.Reader, string) {
+	t.Helper()
+
+	reader, writer := io.Pipe()
+	// Unblocks the writer when the server stops reading early
+	t.Cleanup(func() { reader.Close() })
+	form := multipart.NewWriter(writer)
+	go func() {
+		for name, value := range fields {
+			form.WriteField(name, value)
+		}
+		if fileName != "" {
+			part, err := form.CreateFormFile("file", fileName)
+			if err != nil {
+				writer.CloseWithError(err)
+				return
+			}
+			if _, err := io.Copy(part, content); err != nil {
+				writer.CloseWithError(err)
+				return
+			}
+		}
+		writer.CloseWithError(form.Close())
+	}()
+	return reader, form.FormDataContentType()
+}
+
+func TestAssignmentIDsArePathSafe(t *testing.T) {
+	ts := newTestServer(t, map[string]string{"section1-html-css/week2.md": submissionLesson})
+
+	for id := range ts.assignments {
+		if !studentIDPattern.MatchString(id) || strings.Contains(id, "..") {
+			t.Errorf("assignment id %q is not path-safe", id)
+		}
+	}
+	if _, ok := ts.assignments["escape"]; !ok {
+		t.Fatalf("assignments = %v, want the unsafe id normalized to escape", ts.assignments)
+	}
+
+	client := ts.login("student1")
+	body, contentType := multipartBody(t, `..\..\..\evil.html`, strings.NewReader("<p>hi</p>"), nil)
+	rec := ts.do(client, http.MethodPost, "/api/assignments/escape/submissions", body,
+		map[string]string{"Content-Type": contentType})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+
+	var sub Submission
+	if err := json.NewDecoder(rec.Body).Decode(&sub); err != nil {
+		t.Fatal(err)
+	}
+	if sub.FileName != "evil.html" {
+		t.Errorf("file name = %q, want evil.html", sub.FileName)
+	}
+	stored := filepath.Join(ts.submissions.dir, filepath.FromSlash(sub.StoredPath))
+	if rel, err := filepath.Rel(ts.submissions.dir, stored); err != nil || strings.HasPrefix(rel, "..") {
+		t.Errorf("submission stored at %s, outside %s", stored, ts.submissions.dir)
+	}
+	if _, err := os.Stat(stored); err != nil {
+		t.Errorf("stored file missing: %v", err)
+	}
+}
+
+func TestSubmissionRejectsUnsafeStudentIDs(t *testing.T) {
+	ts := newTestServer(t, map[string]string{"section1-html-css/week2.md": submissionLesson})
+	instructor := ts.login("instructor")
+
+	for _, student := range []string{"../student1", "a/b", ""} {
+		body, contentType := multipartBody(t, "", nil, map[string]string{"student": student, "text": "essay"})
+		rec := ts.do(instructor, http.MethodPost, "/api/assignments/essay/submissions", body,
+			map[string]string{"Content-Type": contentType})
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("student %q: status %d, want %d", student, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestSubmissionLimits(t *testing.T) {
+	ts := newTestServer(t, map[string]string{"section1-html-css/week2.md": submissionLesson})
+	client := ts.login("student1")
+
+	t.Run("disallowed extension", func(t *testing.T) {
+		body, contentType := multipartBody(t, "tool.exe", strings.NewReader("MZ"), nil)
+		rec := ts.do(client, http.MethodPost, "/api/assignments/escape/submissions", body,
+			map[string]string{"Content-Type": contentType})
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status %d, want %d", rec.Code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("text too large", func(t *testing.T) {
+		text := strings.Repeat("a", maxSubmissionText+1)
+		body, contentType := multipartBody(t, "", nil, map[string]string{"text": text})
+		rec := ts.do(client, http.MethodPost, "/api/assignments/essay/submissions", body,
+			map[string]string{"Content-Type": contentType})
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status %d, want %d", rec.Code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("upload too large", func(t *testing.T) {
+		content := io.LimitReader(zeroReader{}, maxSubmissionSize+(2<<20))
+		body, contentType := multipartBody(t, "big.txt", content, nil)
+		rec := ts.do(client, http.MethodPost, "/api/assignments/escape/submissions", body,
+			map[string]string{"Content-Type": contentType})
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("status %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+		}
+		if subs := ts.submissions.list("escape", ""); len(subs) != 0 {
+			t.Errorf("oversized upload recorded: %+v", subs)
+		}
+	})
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
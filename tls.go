@@ -0,0 +1,371 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Files of the generated local CA and server certificate, under the data
+// directory
+const (
+	tlsDirName     = "tls"
+	caCertName     = "ca.pem"
+	caKeyName      = "ca-key.pem"
+	serverCertName = "server.pem"
+	serverKeyName  = "server-key.pem"
+
+	caValidity = 10 * 365 * 24 * time.Hour
+	// Within the 825-day limit browsers apply to trusted server certificates
+	serverCertValidity = 825 * 24 * time.Hour
+	// Reissue the server certificate on start when it expires sooner
+	serverCertRenewBefore = 30 * 24 * time.Hour
+)
+
+// certReloader hands the current certificate to the TLS server and reloads
+// it when the cert or key file changes on disk
+type certReloader struct {
+	certFile string
+	keyFile  string
+	mutex    sync.RWMutex
+	cert     *tls.Certificate
+	watcher  *fsnotify.Watcher
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	c := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate watcher: %w", err)
+	}
+	// Watch the directories, since renewals usually replace the files
+	for _, dir := range []string{filepath.Dir(certFile), filepath.Dir(keyFile)} {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return nil, fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+	}
+	c.watcher = watcher
+	go c.watch()
+	return c, nil
+}
+
+func (c *certReloader) load() error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate: %w", err)
+	}
+
+	c.mutex.Lock()
+	c.cert = &cert
+	c.mutex.Unlock()
+
+	if leaf, err := x509.ParseCertificate(cert.Certificate[0]); err == nil {
+		slog.Info("TLS certificate loaded", "file", c.certFile, "subject", leaf.Subject.CommonName,
+			"names", leaf.DNSNames, "ips", leaf.IPAddresses, "expires", leaf.NotAfter)
+	}
+	return nil
+}
+
+func (c *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.cert, nil
+}
+
+// watch reloads after a change to either file. A failed reload, such as the
+// cert being replaced before its key, keeps the previous certificate.
+func (c *certReloader) watch() {
+	certFile, _ := filepath.Abs(c.certFile)
+	keyFile, _ := filepath.Abs(c.keyFile)
+
+	for {
+		select {
+		case event, ok := <-c.watcher.Events:
+			if !ok {
+				return
+			}
+			name, _ := filepath.Abs(event.Name)
+			if name != certFile && name != keyFile || event.Has(fsnotify.Chmod) {
+				continue
+			}
+			// Let the rest of the write or rename settle and reload once
+			time.Sleep(200 * time.Millisecond)
+			c.drainEvents()
+			if err := c.load(); err != nil {
+				slog.Warn("TLS certificate reload failed, keeping the previous one", "error", err)
+			}
+		case err, ok := <-c.watcher.Errors:
+			if !ok {
+				return
+			}
+			slog.Error("certificate watcher error", "error", err)
+		}
+	}
+}
+
+func (c *certReloader) drainEvents() {
+	for {
+		select {
+		case <-c.watcher.Events:
+		default:
+			return
+		}
+	}
+}
+
+func (c *certReloader) close() error {
+	return c.watcher.Close()
+}
+
+// localNames lists the names and addresses clients may use to reach this
+// machine: localhost, the hostname, every interface address and extra
+func localNames(extra []string) ([]string, []net.IP) {
+	dnsNames := []string{"localhost"}
+	ips := []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		dnsNames = append(dnsNames, hostname)
+	}
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && !ipNet.IP.IsLinkLocalUnicast() {
+				ips = append(ips, ipNet.IP)
+			}
+		}
+	}
+	for _, name := range extra {
+		if ip := net.ParseIP(name); ip != nil {
+			ips = append(ips, ip)
+		} else if name != "" {
+			dnsNames = append(dnsNames, name)
+		}
+	}
+	return dnsNames, ips
+}
+
+// certificateCovers reports whether cert is valid for every name and IP
+func certificateCovers(cert *x509.Certificate, dnsNames []string, ips []net.IP) bool {
+	for _, name := range dnsNames {
+		if cert.VerifyHostname(name) != nil {
+			return false
+		}
+	}
+	for _, ip := range ips {
+		if cert.VerifyHostname(ip.String()) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func writePEM(path, blockType string, der []byte, perm os.FileMode) error {
+	return writeFileAtomic(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), perm)
+}
+
+func readPEM(path, blockType string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("%s: no %s block", path, blockType)
+	}
+	return block.Bytes, nil
+}
+
+func serialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+// loadOrCreateCA returns the local CA in dir, creating it on first use
+func loadOrCreateCA(dir string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	certPath := filepath.Join(dir, caCertName)
+	keyPath := filepath.Join(dir, caKeyName)
+
+	if certDER, err := readPEM(certPath, "CERTIFICATE"); err == nil {
+		keyDER, err := readPEM(keyPath, "EC PRIVATE KEY")
+		if err != nil {
+			return nil, nil, fmt.Errorf("CA certificate exists but its key does not load: %w", err)
+		}
+		cert, err := x509.ParseCertificate(certDER)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+		}
+		key, err := x509.ParseECPrivateKey(keyDER)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse CA key: %w", err)
+		}
+		return cert, key, nil
+	} else if !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate CA key: %w", err)
+	}
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+	hostname, _ := os.Hostname()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "Course App Local CA " + hostname},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create CA certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := writePEM(keyPath, "EC PRIVATE KEY", keyDER, 0600); err != nil {
+		return nil, nil, err
+	}
+	if err := writePEM(certPath, "CERTIFICATE", certDER, 0644); err != nil {
+		return nil, nil, err
+	}
+
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, nil, err
+	}
+	slog.Info("created local certificate authority", "file", certPath)
+	return cert, key, nil
+}
+
+// ensureLocalCertificate makes sure dir holds a server certificate from the
+// local CA that is valid for this machine's current names and addresses,
+// issuing a new one when it is missing, expiring or an address changed. It
+// returns the cert and key paths.
+func ensureLocalCertificate(dir string, extraHosts []string) (string, string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", "", fmt.Errorf("failed to create TLS directory: %w", err)
+	}
+
+	caCert, caKey, err := loadOrCreateCA(dir)
+	if err != nil {
+		return "", "", err
+	}
+
+	certPath := filepath.Join(dir, serverCertName)
+	keyPath := filepath.Join(dir, serverKeyName)
+	dnsNames, ips := localNames(extraHosts)
+
+	if certDER, err := readPEM(certPath, "CERTIFICATE"); err == nil {
+		cert, err := x509.ParseCertificate(certDER)
+		if err == nil && time.Until(cert.NotAfter) > serverCertRenewBefore &&
+			cert.CheckSignatureFrom(caCert) == nil && certificateCovers(cert, dnsNames, ips) {
+			return certPath, keyPath, nil
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate server key: %w", err)
+	}
+	serial, err := serialNumber()
+	if err != nil {
+		return "", "", err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "Course App Server"},
+		DNSNames:     dnsNames,
+		IPAddresses:  ips,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(serverCertValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create server certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	if err := writePEM(keyPath, "EC PRIVATE KEY", keyDER, 0600); err != nil {
+		return "", "", err
+	}
+	if err := writePEM(certPath, "CERTIFICATE", certDER, 0644); err != nil {
+		return "", "", err
+	}
+
+	slog.Info("issued server certificate from local CA", "file", certPath, "names", dnsNames, "ips", ips)
+	return certPath, keyPath, nil
+}
+
+// handleCACert serves the local CA certificate so kiosks can install it
+func (s *Server) handleCACert(w http.ResponseWriter, r *http.Request) {
+	data, err := os.ReadFile(s.caCertFile)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	w.Header().Set("Content-Disposition", `attachment; filename="course-ca.pem"`)
+	w.Write(data)
+}
+
+// redirectToHTTPS sends plain HTTP requests to the TLS listener, keeping the
+// host the client used. The local CA certificate stays downloadable over
+// HTTP, since clients need it before they can trust the TLS listener.
+func (s *Server) redirectToHTTPS(tlsAddr string) http.Handler {
+	_, tlsPort, _ := net.SplitHostPort(tlsAddr)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ca.pem" && s.caCertFile != "" {
+			s.handleCACert(w, r)
+			return
+		}
+
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		if tlsPort != "" && tlsPort != "443" {
+			host += ":" + tlsPort
+		}
+
+		status := http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			status = http.StatusMovedPermanently
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), status)
+	})
+}